@@ -12,33 +12,35 @@ import (
 )
 
 // worker function to calculate next state for a specific region of the world.
-func worker(turn int, startY, endY, startX, endX int, temp_world [][]uint8, world [][]uint8, worldHeight int, worldWidth int, wg *sync.WaitGroup) {
+func worker(rule gol.Rule, boundary gol.Boundary, turn int, startY, endY, startX, endX int, temp_world [][]uint8, world [][]uint8, worldHeight int, worldWidth int, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	// Larger than Life neighbourhoods are counted for the whole region up front
+	var counts [][]int
+	if rule.IsLargerThanLife() {
+		counts = rule.CountNeighbours(temp_world, boundary, startY, endY, startX, endX)
+	}
+
 	for i := startY; i < endY; i++ {
 		for j := startX; j < endX; j++ {
-			sum := int(temp_world[(i-1+worldHeight)%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[i%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[(i-1+worldHeight)%worldHeight][j%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][j%worldWidth]) +
-				int(temp_world[(i-1+worldHeight)%worldHeight][(j+1)%worldWidth]) +
-				int(temp_world[i%worldHeight][(j+1)%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][(j+1)%worldWidth])
-
-			if temp_world[i][j] == 255 {
-				if sum < 2*255 || sum > 3*255 {
-					world[i][j] = 0
-				} else {
-					world[i][j] = temp_world[i][j]
-				}
+			var neighbours int
+			if counts != nil {
+				neighbours = counts[i-startY][j-startX]
+			} else if boundary != gol.Torus && (i == 0 || i == worldHeight-1 || j == 0 || j == worldWidth-1) {
+				neighbours = boundary.Neighbours(temp_world, j, i)
 			} else {
-				if sum == 3*255 {
-					world[i][j] = 255
-				} else {
-					world[i][j] = temp_world[i][j]
-				}
+				// Only alive cells count, dying cells of Generations rules hold grey levels below 255
+				neighbours = int(temp_world[(i-1+worldHeight)%worldHeight][(j-1+worldWidth)%worldWidth]/255) +
+					int(temp_world[i%worldHeight][(j-1+worldWidth)%worldWidth]/255) +
+					int(temp_world[(i+1)%worldHeight][(j-1+worldWidth)%worldWidth]/255) +
+					int(temp_world[(i-1+worldHeight)%worldHeight][j%worldWidth]/255) +
+					int(temp_world[(i+1)%worldHeight][j%worldWidth]/255) +
+					int(temp_world[(i-1+worldHeight)%worldHeight][(j+1)%worldWidth]/255) +
+					int(temp_world[i%worldHeight][(j+1)%worldWidth]/255) +
+					int(temp_world[(i+1)%worldHeight][(j+1)%worldWidth]/255)
 			}
+
+			world[i][j] = rule.Step(temp_world[i][j], neighbours)
 		}
 	}
 }
@@ -54,7 +56,7 @@ var quitting bool = false
 var aliveCells []util.Cell
 
 // Update world by workers and return final result after all turns complete
-func UpdateWorld(request gol.Request) gol.FinalResponse {
+func UpdateWorld(request gol.Request, rule gol.Rule) gol.FinalResponse {
 	var wg sync.WaitGroup
 	workerNum := request.Parameters.Threads
 	totalTurns := request.Parameters.Turns
@@ -89,9 +91,9 @@ func UpdateWorld(request gol.Request) gol.FinalResponse {
 				endY := unitY * i
 				if i == workerNum {
 					leftY := worldHeight - (i-1)*unitY //can change start+leftY to worldHeight
-					go worker(turn, startY, startY+leftY, 0, worldWidth, temp_world, world, worldHeight, worldWidth, &wg)
+					go worker(rule, request.Parameters.Boundary, turn, startY, startY+leftY, 0, worldWidth, temp_world, world, worldHeight, worldWidth, &wg)
 				} else {
-					go worker(turn, startY, endY, 0, worldWidth, temp_world, world, worldHeight, worldWidth, &wg)
+					go worker(rule, request.Parameters.Boundary, turn, startY, endY, 0, worldWidth, temp_world, world, worldHeight, worldWidth, &wg)
 				}
 			}
 			// Wait for all workers to complete
@@ -142,6 +144,8 @@ func UpdateWorld(request gol.Request) gol.FinalResponse {
 		finalWorld,
 		aliveCells,
 		turn,
+		gol.CycleDetected{},
+		false,
 	}
 
 	return finalResponse
@@ -152,7 +156,11 @@ type UpdateGOLWorld struct{}
 
 // UpdateWorld (RPC)
 func (u *UpdateGOLWorld) UpdateWorld_RPC(request gol.Request, response *gol.FinalResponse) error {
-	*response = UpdateWorld(request)
+	rule, err := gol.ParseRule(request.Parameters.Rule)
+	if err != nil {
+		return err
+	}
+	*response = UpdateWorld(request, rule)
 	return nil
 }
 
@@ -193,6 +201,8 @@ func (s *SaveCurrentGOLWorld) SaveCurrentWorld_RPC(request struct{}, response *g
 	*response = gol.CurrentResponse{
 		CurrentWorld,
 		turn,
+		false,
+		false,
 	}
 	Keymtx.Unlock()
 	return nil
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// endWhenDetached passes the events of a run on to events, and ends the run once the test has finished if 'q'
+// detached from it, so it does not go on sharing the nodes with the runs of later tests.
+func endWhenDetached(t *testing.T, events chan<- gol.Event) chan<- gol.Event {
+	runEvents := make(chan gol.Event, 1000)
+	detached := make(chan int, 1)
+	go func() {
+		for event := range runEvents {
+			if e, ok := event.(gol.SessionDetached); ok {
+				detached <- e.Session
+			}
+			events <- event
+		}
+		close(events)
+	}()
+	t.Cleanup(func() {
+		select {
+		case session := <-detached:
+			endSession(session)
+		default:
+		}
+	})
+	return runEvents
+}
+
+// endSession attaches to the run of a detached session and ends it with 'x', returning its final turn.
+func endSession(session int) gol.FinalTurnComplete {
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 1)
+	keyPresses <- 'x'
+	go gol.Run(gol.Params{Attach: session}, events, keyPresses)
+	var final gol.FinalTurnComplete
+	for event := range events {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e
+		}
+	}
+	return final
+}
+
+// TestAttach tests that 'q' detaches from a run the broker goes on with, and that attaching to it again reports
+// the whole world reached before the run ends with the correct cells.
+func TestAttach(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, TurnsPerSecond: 20}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 1)
+	go gol.Run(p, events, keyPresses)
+	var detached gol.SessionDetached
+	for event := range events {
+		switch e := event.(type) {
+		case gol.AliveCellsCount:
+			keyPresses <- 'q'
+		case gol.SessionDetached:
+			detached = e
+		case gol.FinalTurnComplete:
+			t.Errorf("run detached from completed %v turns", e.CompletedTurns)
+		}
+	}
+	if detached.Session == 0 {
+		t.Fatal("no SessionDetached event after 'q'")
+	}
+
+	attached, err := gol.SessionParams(detached.Session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attached.ImageWidth != p.ImageWidth || attached.Turns != p.Turns || attached.TurnsPerSecond != p.TurnsPerSecond {
+		t.Errorf("session parameters %+v, expected those of %+v", attached, p)
+	}
+
+	initial := readAliveCells("check/images/64x64x0.pgm", 64, 64)
+	events = make(chan gol.Event)
+	go gol.Run(gol.Params{Attach: detached.Session}, events, nil)
+	var cells []util.Cell
+	first := true
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellsFlipped:
+			if !first {
+				break
+			}
+			first = false
+			if e.CompletedTurns < detached.CompletedTurns {
+				t.Errorf("attached at turn %v, before the turn %v detached at", e.CompletedTurns, detached.CompletedTurns)
+			}
+			turn := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: e.CompletedTurns}
+			assertEqualBoard(t, e.Cells, simulate(initial, turn), turn)
+
+			// The session is attached, so a second controller may not attach to it
+			second := make(chan gol.Event)
+			go gol.Run(gol.Params{Attach: detached.Session}, second, nil)
+			if _, ok := (<-second).(gol.ImageInputFailed); !ok {
+				t.Error("a second controller attached to the session")
+			}
+			for range second {
+			}
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	assertEqualBoard(t, cells, readAliveCells("check/images/64x64x100.pgm", 64, 64), p)
+}
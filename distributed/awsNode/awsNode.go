@@ -7,110 +7,252 @@ import (
 	"net"
 	"net/rpc"
 	"sync"
+	"time"
 
-	"uk.ac.bris.cs/gameoflife/util"
+	"uk.ac.bris.cs/gameoflife/gol"
 )
 
-type BrokerRequest struct {
+// Request to keep a strip of rows of the world between turns
+type StripRequest struct {
+	Strip       int
 	StartY      int
 	EndY        int
-	StartX      int
-	EndX        int
-	World       [][]uint8
+	Rows        [][]uint8
 	WorldHeight int
 	WorldWidth  int
+	Rule        gol.Rule
+	Boundary    gol.Boundary
 }
 
-type BrokerResponse struct {
-	World           [][]uint8
-	StartY          int
-	EndY            int
+// Request to run a turn of a strip, given the rows around it it reads, indexed by their row in the world
+type StepRequest struct {
+	Strip int
+	Halo  map[int][]uint8
+	Hash  bool
+}
+
+// The rows of a strip its neighbours read, indexed by their row in the world, after a turn
+type StepResponse struct {
+	Edges           map[int][]uint8
 	AliveCellsCount int
+	Hash            uint64
+}
+
+// The rows of a strip, fetched when the broker needs the whole world
+type StripRows struct {
+	Rows [][]uint8
 }
 
+// A strip of rows the node keeps between turns. The workers read a view of the world of which only the rows of the
+// strip and the halo around it are filled in, the others are a shared row of dead cells.
+type strip struct {
+	request StripRequest
+	current [][]uint8
+	next    [][]uint8
+	dead    []uint8
+	mtx     sync.Mutex
+}
+
+var strips = make(map[int]*strip)
+var stripsMtx sync.Mutex
+
 var waitRPC sync.WaitGroup
 
-// worker function to calculate next state for a specific region of the world.
-func worker(startY, endY, startX, endX int, temp_world [][]uint8, world [][]uint8, worldHeight int, worldWidth int, aliveCells *[]util.Cell, aliveCellsCount *int, workermtx *sync.Mutex) {
+// worker function to calculate next state for a specific region of the world, returning how many of its cells are alive.
+func worker(rule gol.Rule, boundary gol.Boundary, startY, endY, startX, endX int, temp_world [][]uint8, world [][]uint8, worldHeight int, worldWidth int) int {
+	aliveCellsCount := 0
+	// Larger than Life neighbourhoods are counted for the whole region up front
+	var counts [][]int
+	if rule.IsLargerThanLife() {
+		counts = rule.CountNeighbours(temp_world, boundary, startY, endY, startX, endX)
+	}
+
 	for i := startY; i < endY; i++ {
 		for j := startX; j < endX; j++ {
-			sum := int(temp_world[(i-1+worldHeight)%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[i%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[(i-1+worldHeight)%worldHeight][j%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][j%worldWidth]) +
-				int(temp_world[(i-1+worldHeight)%worldHeight][(j+1)%worldWidth]) +
-				int(temp_world[i%worldHeight][(j+1)%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][(j+1)%worldWidth])
-
-			if temp_world[i][j] == 255 {
-				if sum < 2*255 || sum > 3*255 {
-					world[i][j] = 0
-				} else {
-					world[i][j] = temp_world[i][j]
-					workermtx.Lock()
-					*aliveCells = append(*aliveCells, util.Cell{j, i})
-					(*aliveCellsCount)++
-					workermtx.Unlock()
-				}
+			var neighbours int
+			if counts != nil {
+				neighbours = counts[i-startY][j-startX]
+			} else if boundary != gol.Torus && (i == 0 || i == worldHeight-1 || j == 0 || j == worldWidth-1) {
+				neighbours = boundary.Neighbours(temp_world, j, i)
 			} else {
-				if sum == 3*255 {
-					world[i][j] = 255
-					workermtx.Lock()
-					*aliveCells = append(*aliveCells, util.Cell{j, i})
-					(*aliveCellsCount)++
-					workermtx.Unlock()
-				} else {
-					world[i][j] = temp_world[i][j]
-				}
+				// Only alive cells count, dying cells of Generations rules hold grey levels below 255
+				neighbours = int(temp_world[(i-1+worldHeight)%worldHeight][(j-1+worldWidth)%worldWidth]/255) +
+					int(temp_world[i%worldHeight][(j-1+worldWidth)%worldWidth]/255) +
+					int(temp_world[(i+1)%worldHeight][(j-1+worldWidth)%worldWidth]/255) +
+					int(temp_world[(i-1+worldHeight)%worldHeight][j%worldWidth]/255) +
+					int(temp_world[(i+1)%worldHeight][j%worldWidth]/255) +
+					int(temp_world[(i-1+worldHeight)%worldHeight][(j+1)%worldWidth]/255) +
+					int(temp_world[i%worldHeight][(j+1)%worldWidth]/255) +
+					int(temp_world[(i+1)%worldHeight][(j+1)%worldWidth]/255)
+			}
+
+			world[i][j] = rule.Step(temp_world[i][j], neighbours)
+			if world[i][j] == 255 {
+				aliveCellsCount++
 			}
 		}
 	}
+	return aliveCellsCount
 }
 
 type Broker struct{}
 
-func (b *Broker) UpdateWorld_RPC(brokerRequest BrokerRequest, brokerResponse *BrokerResponse) error {
+// RPC for LoadStrip, keeps the rows of a strip until it is dropped
+func (b *Broker) LoadStrip_RPC(stripRequest StripRequest, _ *struct{}) error {
 	waitRPC.Add(1)
-	world := brokerRequest.World
-	temp_world := make([][]uint8, brokerRequest.WorldHeight)
-	for i := 0; i < brokerRequest.WorldHeight; i++ {
-		temp_world[i] = make([]uint8, brokerRequest.WorldWidth)
-		copy(temp_world[i], world[i])
+	defer waitRPC.Done()
+	s := &strip{request: stripRequest, dead: make([]uint8, stripRequest.WorldWidth)}
+	s.current = make([][]uint8, len(stripRequest.Rows))
+	s.next = make([][]uint8, len(stripRequest.Rows))
+	for i, row := range stripRequest.Rows {
+		s.current[i] = row
+		s.next[i] = make([]uint8, len(row))
+	}
+	stripRequest.Rows = nil
+	stripsMtx.Lock()
+	strips[stripRequest.Strip] = s
+	stripsMtx.Unlock()
+	return nil
+}
+
+// RPC for DropStrips, forgets strips the broker no longer gives turns of
+func (b *Broker) DropStrips_RPC(indices []int, _ *struct{}) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	stripsMtx.Lock()
+	defer stripsMtx.Unlock()
+	for _, index := range indices {
+		delete(strips, index)
+	}
+	return nil
+}
+
+// Look up a strip loaded by LoadStrip_RPC
+func findStrip(index int) (*strip, error) {
+	stripsMtx.Lock()
+	defer stripsMtx.Unlock()
+	s, ok := strips[index]
+	if !ok {
+		return nil, fmt.Errorf("strip %v is not loaded", index)
+	}
+	return s, nil
+}
+
+// RPC for StepStrip, runs a turn of a strip and returns the rows at its edges
+func (b *Broker) StepStrip_RPC(stepRequest StepRequest, stepResponse *StepResponse) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findStrip(stepRequest.Strip)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	request := s.request
+	temp_world := make([][]uint8, request.WorldHeight)
+	world := make([][]uint8, request.WorldHeight)
+	for i := range temp_world {
+		temp_world[i] = s.dead
+	}
+	for y, row := range stepRequest.Halo {
+		temp_world[y] = row
+	}
+	for i := range s.current {
+		temp_world[request.StartY+i] = s.current[i]
+		world[request.StartY+i] = s.next[i]
 	}
 
-	unitX := (brokerRequest.EndX - brokerRequest.StartX) / 8
-	var aliveCells []util.Cell = make([]util.Cell, 0)
-	var aliveCellsCount int = 0
+	unitX := request.WorldWidth / 8
+	// Each worker counts the alive cells of its own region, they are summed once all have finished
+	counts := make([]int, 8)
 	var workerwg sync.WaitGroup
-	var workermtx sync.Mutex
 	workerwg.Add(8)
 	for i := 0; i < 8; i++ {
-		startY := brokerRequest.StartY
-		endY := brokerRequest.EndY
 		startX := unitX * i
 		endX := unitX * (i + 1)
-		go func(startX int, endX int) {
+		if i == 7 {
+			endX = request.WorldWidth
+		}
+		go func(i int, startX int, endX int) {
 			defer workerwg.Done()
-			worker(startY, endY, startX, endX, temp_world, world, brokerRequest.WorldHeight, brokerRequest.WorldWidth, &aliveCells, &aliveCellsCount, &workermtx)
-		}(startX, endX)
+			counts[i] = worker(request.Rule, request.Boundary, request.StartY, request.EndY, startX, endX, temp_world, world, request.WorldHeight, request.WorldWidth)
+		}(i, startX, endX)
 	}
-
 	workerwg.Wait()
-	*brokerResponse = BrokerResponse{
-		world,
-		brokerRequest.StartY,
-		brokerRequest.EndY,
-		aliveCellsCount,
+	aliveCellsCount := 0
+	for _, count := range counts {
+		aliveCellsCount += count
+	}
+	s.current, s.next = s.next, s.current
+
+	// Only the rows the neighbouring strips read are returned, the hash of the strip only if asked for
+	*stepResponse = StepResponse{Edges: make(map[int][]uint8), AliveCellsCount: aliveCellsCount}
+	depth := 1
+	if request.Rule.IsLargerThanLife() {
+		depth = request.Rule.Range
+	}
+	for d := 0; d < depth && d < len(s.current); d++ {
+		stepResponse.Edges[request.StartY+d] = append([]uint8(nil), s.current[d]...)
+		stepResponse.Edges[request.EndY-1-d] = append([]uint8(nil), s.current[len(s.current)-1-d]...)
+	}
+	if stepRequest.Hash {
+		for i, row := range s.current {
+			for x, value := range row {
+				stepResponse.Hash ^= gol.CellHash(x, request.StartY+i, value)
+			}
+		}
 	}
-	waitRPC.Done()
 	return nil
 }
 
+// RPC for FetchStrip, returns the rows of a strip
+func (b *Broker) FetchStrip_RPC(index int, stripRows *StripRows) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findStrip(index)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stripRows.Rows = s.current
+	return nil
+}
+
+// How long dialing the broker may take, and the longest back-off between attempts while it is not up
+const dialTimeout = 2 * time.Second
+const maxBackOff = 10 * time.Second
+
+// Register the node listening on nodeAddress with the broker, retrying with an exponential back-off until it answers
+func registerNode(brokerAddress string, nodeAddress string) {
+	backOff := 100 * time.Millisecond
+	for {
+		conn, err := net.DialTimeout("tcp", brokerAddress, dialTimeout)
+		if err == nil {
+			client := rpc.NewClient(conn)
+			var registered bool
+			err = client.Call("Broker.RegisterNode_RPC", nodeAddress, &registered)
+			client.Close()
+			if err == nil {
+				fmt.Println("Registered with the broker as", nodeAddress)
+				return
+			}
+		}
+		log.Printf("Registering with broker %v failed, retrying in %v: %v", brokerAddress, backOff, err)
+		time.Sleep(backOff)
+		if backOff *= 2; backOff > maxBackOff {
+			backOff = maxBackOff
+		}
+	}
+}
+
 func main() {
 	// Listen to broker connection
 	f := flag.String("ip", "0.0.0.0", "ip to listen on")
 	p := flag.String("port", "8080", "ip to listen on")
+	brokerAddress := flag.String("broker", "", "address of a broker to register with, such as 127.0.0.1:8030")
+	advertise := flag.String("advertise", "", "address the broker dials this node on, defaults to ip:port or 127.0.0.1:port")
 	flag.Parse()
 	ip := fmt.Sprintf("%s", *f)
 	port := fmt.Sprintf("%s", *p)
@@ -127,6 +269,17 @@ func main() {
 	broker := new(Broker)
 	rpc.Register(broker)
 
+	// Register with the broker once listening, so it can dial back
+	if *brokerAddress != "" {
+		nodeAddress := *advertise
+		if nodeAddress == "" && (ip == "0.0.0.0" || ip == "") {
+			nodeAddress = "127.0.0.1:" + port
+		} else if nodeAddress == "" {
+			nodeAddress = address
+		}
+		go registerNode(*brokerAddress, nodeAddress)
+	}
+
 	// Accept iteratelly new connection from broker
 	for {
 		conn, err := ln.Accept()
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestBoundaryCell tests which cell lies beyond each edge of a 4x3 world for every boundary mode.
+func TestBoundaryCell(t *testing.T) {
+	world := [][]uint8{
+		{0, 1, 2, 3},
+		{4, 5, 6, 7},
+		{8, 9, 10, 11},
+	}
+	tests := []struct {
+		boundary gol.Boundary
+		x, y     int
+		expected uint8
+	}{
+		{gol.Torus, -1, 0, 3},
+		{gol.Torus, 4, 3, 0},
+		{gol.Torus, 1, -1, 9},
+		{gol.Dead, -1, 0, 0},
+		{gol.Dead, 2, 3, 0},
+		{gol.Reflect, -1, 0, 0},
+		{gol.Reflect, 4, 1, 7},
+		{gol.Reflect, 1, -1, 1},
+		{gol.Reflect, 1, 3, 9},
+		{gol.Klein, -1, 1, 7},
+		{gol.Klein, 1, -1, 10},
+		{gol.Klein, 0, 3, 3},
+		{gol.CylinderX, -1, 1, 7},
+		{gol.CylinderX, 1, -1, 0},
+		{gol.CylinderY, -1, 1, 0},
+		{gol.CylinderY, 1, -1, 9},
+	}
+	for _, test := range tests {
+		if cell := test.boundary.Cell(world, test.x, test.y); cell != test.expected {
+			t.Errorf("%v: cell at (%v, %v) is %v, expected %v", test.boundary, test.x, test.y, cell, test.expected)
+		}
+	}
+	for _, name := range []string{"torus", "dead", "reflect", "klein", "cylinder-x", "cylinder-y", "unbounded"} {
+		boundary, err := gol.ParseBoundary(name)
+		if err != nil || boundary.String() != name {
+			t.Errorf("ParseBoundary(%q) = %v, %v", name, boundary, err)
+		}
+	}
+	if _, err := gol.ParseBoundary("sphere"); err == nil {
+		t.Errorf("ParseBoundary(%q) should have returned an error", "sphere")
+	}
+}
+
+// TestBoundary compares each boundary mode against a straightforward simulation for 16x16 and 64x64 images.
+func TestBoundary(t *testing.T) {
+	for _, size := range []int{16, 64} {
+		for _, boundary := range []gol.Boundary{gol.Dead, gol.Reflect, gol.Klein, gol.CylinderX, gol.CylinderY} {
+			p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: 50, Threads: 8, Boundary: boundary}
+			expectedAlive := simulate(readAliveCells(fmt.Sprintf("check/images/%vx%vx0.pgm", size, size), size, size), p)
+			t.Run(fmt.Sprintf("%dx%d-%v", size, size, boundary), func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for event := range events {
+					if e, ok := event.(gol.FinalTurnComplete); ok {
+						assertEqualBoard(t, e.Alive, expectedAlive, p)
+					}
+				}
+			})
+		}
+	}
+}
+
+// simulate runs B3/S23 one cell at a time, looking beyond the edges through the boundary.
+func simulate(alive []util.Cell, p gol.Params) []util.Cell {
+	world := make([][]uint8, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]uint8, p.ImageWidth)
+	}
+	for _, cell := range alive {
+		world[cell.Y][cell.X] = 255
+	}
+	for turn := 0; turn < p.Turns; turn++ {
+		next := make([][]uint8, p.ImageHeight)
+		for y := range next {
+			next[y] = make([]uint8, p.ImageWidth)
+			for x := range next[y] {
+				neighbours := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if (dx != 0 || dy != 0) && p.Boundary.Cell(world, x+dx, y+dy) == 255 {
+							neighbours++
+						}
+					}
+				}
+				if neighbours == 3 || (neighbours == 2 && world[y][x] == 255) {
+					next[y][x] = 255
+				}
+			}
+		}
+		world = next
+	}
+	var result []util.Cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 255 {
+				result = append(result, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return result
+}
@@ -1,150 +1,228 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/rpc"
 	"sync"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
-type Controler struct{}
-
-type BrokerRequest struct {
-	StartY      int
-	EndY        int
-	StartX      int
-	EndX        int
-	World       [][]uint8
-	WorldHeight int
-	WorldWidth  int
+// Controler serves the RPCs of one connection of a controller, closed is closed once the connection drops
+type Controler struct {
+	closed <-chan struct{}
 }
 
-type BrokerResponse struct {
-	World           [][]uint8
-	StartY          int
-	EndY            int
-	AliveCellsCount int
+// A connection that tells when it drops, reading from it fails once it has. The RPCs served on it are still
+// running then, a run is not kept waiting on a controller that is gone.
+type controlerConn struct {
+	net.Conn
+	once   sync.Once
+	closed chan struct{}
 }
 
-// aws nodes address
-var awsNodeIPs [4]string = [4]string{"184.72.68.197", "44.206.242.85", "3.86.159.174", "44.211.143.22"}
-var port [4]string = [4]string{"8080", "8080", "8080", "8080"}
-
-// address for testing
-// var awsNodeIPs [4]string = [4]string{"127.0.0.1", "127.0.0.1", "127.0.0.1", "127.0.0.1"}
-// var port [4]string = [4]string{"8080", "8081", "8082", "8083"}
-
-var brokers = make([]*rpc.Client, 16)
+func (c *controlerConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.once.Do(func() { close(c.closed) })
+	}
+	return n, err
+}
 
-// Global variables
-var turn int = 0
-var pausing bool = false
+// Global variables, the state of each run is kept by its session
 var closing bool = false
-var quitting bool = false
-var currentWorld [][]uint8
-var currentAliveCellsCount int = 0
-var responsesMtx sync.Mutex
-var keyPressMtx sync.Mutex
-var countAliveCellsMtx sync.Mutex
+var fetchInterval = 5 * time.Second // longest time between fetching the world, set by the -fetch-interval flag
 var waitRPC sync.WaitGroup
 
 // Run the game
-func runGameBrokerCall(controlerRequest gol.Request) gol.FinalResponse {
+func (s *session) runGame(controlerRequest gol.Request, rule gol.Rule, monitor *gol.Monitor) gol.FinalResponse {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
 
 	// Declare all variable to be used during the process
-	var nodeswg sync.WaitGroup
 	var currentAliveCells []util.Cell
 
-	currentWorld = controlerRequest.InitialWorld
-
-	closing = false
-	quitting = false
-
-	// Each turn calling RPC to update world
-	for turn < controlerRequest.Parameters.Turns {
-		combineResponse := make([]BrokerResponse, 0)
-
-		keyPressMtx.Lock()
-		if !pausing {
-			countAliveCellsMtx.Lock()
-			nodeswg.Add(controlerRequest.Parameters.Threads)
-			currentAliveCellsCount = 0
-
-			// Assigning tasks to GolEngine server
-			for i := 1; i <= controlerRequest.Parameters.Threads; i++ {
-				unitY := controlerRequest.Parameters.ImageHeight / controlerRequest.Parameters.Threads
-				var brokerRequest BrokerRequest
-				if i < controlerRequest.Parameters.Threads {
-					// Create request for RPC
-					brokerRequest = BrokerRequest{
-						unitY * (i - 1),
-						unitY * i,
-						0,
-						controlerRequest.Parameters.ImageWidth,
-						currentWorld,
-						controlerRequest.Parameters.ImageHeight,
-						controlerRequest.Parameters.ImageWidth,
-					}
-				} else if i == controlerRequest.Parameters.Threads {
-					// Create request for RPC
-					brokerRequest = BrokerRequest{
-						unitY * (i - 1),
-						controlerRequest.Parameters.ImageHeight,
-						0,
-						controlerRequest.Parameters.ImageWidth,
-						currentWorld,
-						controlerRequest.Parameters.ImageHeight,
-						controlerRequest.Parameters.ImageWidth,
-					}
-				}
+	s.partMtx.Lock()
+	s.keyPressMtx.Lock()
+	s.currentWorld = controlerRequest.InitialWorld
+	s.turn = controlerRequest.Turn
+	s.currentWorldTurn = s.turn
+	s.fetchedAt = time.Now()
+	s.partTurn = s.turn
+	// A key press may have started recording before the run did
+	if controlerRequest.Parameters.Recording.Start {
+		s.recording = true
+	}
+	s.keyPressMtx.Unlock()
+	s.partMtx.Unlock()
+
+	// Hash each generation to detect cycles, the first one found is returned with the final response
+	var cycles *gol.CycleDetector
+	var cycle gol.CycleDetected
+	if controlerRequest.Parameters.CycleHistory > 0 {
+		cycles = gol.NewCycleDetector(controlerRequest.Parameters.CycleHistory)
+		cycles.Add(controlerRequest.Turn, gol.HashWorld(controlerRequest.InitialWorld))
+	}
 
-				// Calling RPC to update world
-				go func(brokerIndex int) {
-					var brokerResponse BrokerResponse
-					defer nodeswg.Done()
-					brokers[(brokerIndex+5)%4].Call("Broker.UpdateWorld_RPC", brokerRequest, &brokerResponse)
-					responsesMtx.Lock()
-					combineResponse = append(combineResponse, brokerResponse)
-					responsesMtx.Unlock()
-				}(i)
+	// Snapshots, checkpoints and frames of the last turn, sent once its world is fetched from the nodes
+	var saveDue, checkpointDue, frameDue bool
+	dueSnapshots := func() []gol.CurrentResponse {
+		var pending []gol.CurrentResponse
+		if saveDue {
+			pending = append(pending, gol.CurrentResponse{s.currentWorld, s.turn, false, false})
+		}
+		if checkpointDue {
+			pending = append(pending, gol.CurrentResponse{s.currentWorld, s.turn, true, false})
+		}
+		if frameDue {
+			pending = append(pending, gol.CurrentResponse{s.currentWorld, s.turn, false, true})
+		}
+		saveDue, checkpointDue, frameDue = false, false, false
+		return pending
+	}
+	threads := controlerRequest.Parameters.Threads
+	boundary := controlerRequest.Parameters.Boundary
+
+	// Each turn calling RPC to update world. The strips are only changed holding partMtx, and keyPressMtx is not
+	// held while the nodes are called, so keys are answered while a node takes long to fail.
+	for s.turn < controlerRequest.Parameters.Turns {
+		var pending []gol.CurrentResponse
+		stop := false
+
+		// Divide the turn between the nodes that are alive, waiting for one to join if there are none
+		nodes := registry.alive()
+
+		s.partMtx.Lock()
+		// Turns lost with a node are run again straight away, whether paused or not, as is a turn that was being run
+		// when the run was paused
+		s.keyPressMtx.Lock()
+		replay := s.partTurn < s.turn
+		stepping := s.stepping
+		run := len(nodes) > 0 && (replay || (s.pausing && s.turn < s.pausedAt) || (!s.pausing && s.throttle.Due()) || stepping)
+		s.inFlight = run && !replay
+		s.keyPressMtx.Unlock()
+
+		var newTurn bool
+		var aliveCellsCount int
+		var worldHash uint64
+		if run && s.dealStrips(nodes, threads, rule, boundary) {
+			// The nodes keep their strips and only exchange the rows around them
+			newTurn = s.partTurn == s.turn
+			var err error
+			aliveCellsCount, worldHash, err = s.part.step(newTurn && cycles != nil && cycle.Period == 0)
+			if err != nil {
+				s.nodeLost()
+			} else {
+				s.partTurn++
+			}
+			newTurn = newTurn && err == nil
+		}
 
+		// Publish the turn run, and read the controls for the next one
+		s.keyPressMtx.Lock()
+		s.inFlight = false
+		if newTurn {
+			s.countAliveCellsMtx.Lock()
+			s.currentAliveCellsCount = aliveCellsCount
+			s.turn = s.partTurn
+			s.countAliveCellsMtx.Unlock()
+
+			// Report the turn a single step while paused finished on
+			if stepping && s.stepping {
+				s.stepping = false
+				s.steppedTurn <- s.turn
 			}
-			nodeswg.Wait()
-			// Combine work result from the nodes
-			for n := 0; n < controlerRequest.Parameters.Threads; n++ {
-				nodeResponse := combineResponse[n]
-				sliceWorld := nodeResponse.World
-				startY := nodeResponse.StartY
-				endY := nodeResponse.EndY
-				currentAliveCellsCount += nodeResponse.AliveCellsCount
-				// Update a slice of current world handled by a node
-				for i := startY; i < endY; i++ {
-					copy(currentWorld[i], sliceWorld[i])
+		}
+		recording := s.recording
+		pace := s.throttle
+		idle := s.pausing
+		quitting := s.quitting
+		detached := s.detached
+		s.keyPressMtx.Unlock()
+
+		if newTurn {
+			if cycles != nil && cycle.Period == 0 {
+				if firstSeen, ok := cycles.Add(s.turn, worldHash); ok {
+					cycle = gol.CycleDetected{s.turn, s.turn - firstSeen, firstSeen}
 				}
 			}
-			turn++
-			countAliveCellsMtx.Unlock()
 
+			// Check the conditions of the run, snapshots and checkpoints are fetched from the nodes for the client to write
+			var save bool
+			stop, save = monitor.Check(s.turn, aliveCellsCount)
+			checkpoints := controlerRequest.Parameters.Checkpoints
+			saveDue = saveDue || save
+			checkpointDue = checkpointDue || (checkpoints > 0 && s.turn%checkpoints == 0)
+			frameDue = frameDue || (recording && controlerRequest.Parameters.Recording.Records(s.turn))
 		}
-		keyPressMtx.Unlock()
 
-		// Break Broker game run if keyPress "q" or "k"
-		if quitting || closing {
+		// The world is also fetched now and then, so few turns are run again when a node is lost
+		if s.part != nil && s.partTurn == s.turn && (saveDue || checkpointDue || frameDue || time.Since(s.fetchedAt) > fetchInterval) {
+			if s.fetchWorld() {
+				pending = dueSnapshots()
+			}
+		}
+		s.partMtx.Unlock()
+
+		for _, snapshot := range pending {
+			s.sendSnapshot(snapshot, detached)
+		}
+
+		// Wait for the next turn rather than spin when it is not due yet, or for a node to join
+		if len(nodes) == 0 {
+			time.Sleep(minBackOff)
+		} else if !idle {
+			pace.Wait(10 * time.Millisecond)
+		}
+
+		// Break Broker game run if keyPress "x" or "k", once a cycle is detected if requested, or a stop condition holds
+		if quitting || closing || (cycle.Period > 0 && controlerRequest.Parameters.StopOnCycle) || stop {
 
 			break
 		}
 	}
 
+	// Fetch the final world, running the turns lost with a node again if one fails. The strips are dropped with the run.
+	s.partMtx.Lock()
+	for s.currentWorldTurn < s.turn {
+		nodes := registry.alive()
+		if len(nodes) == 0 {
+			s.partMtx.Unlock()
+			time.Sleep(minBackOff)
+			s.partMtx.Lock()
+		} else if s.dealStrips(nodes, threads, rule, boundary) {
+			if s.partTurn < s.turn {
+				if _, _, err := s.part.step(false); err != nil {
+					s.nodeLost()
+				} else {
+					s.partTurn++
+				}
+			} else {
+				s.fetchWorld()
+			}
+		}
+	}
+	s.dropStrips()
+	pending := dueSnapshots()
+	finalWorld := s.currentWorld
+	s.partMtx.Unlock()
+
+	s.keyPressMtx.Lock()
+	detached := s.detached
+	s.keyPressMtx.Unlock()
+	for _, snapshot := range pending {
+		s.sendSnapshot(snapshot, detached)
+	}
+
 	// Construct final alive cells
 	for j := 0; j < controlerRequest.Parameters.ImageHeight; j++ {
 		for k := 0; k < controlerRequest.Parameters.ImageWidth; k++ {
-			if currentWorld[j][k] == 255 {
+			if finalWorld[j][k] == 255 {
 				currentAliveCells = append(currentAliveCells, util.Cell{k, j})
 			}
 		}
@@ -152,64 +230,249 @@ func runGameBrokerCall(controlerRequest gol.Request) gol.FinalResponse {
 
 	// Construct final response to send to client
 	controlerResponse := gol.FinalResponse{
-		currentWorld,
+		finalWorld,
 		currentAliveCells,
-		turn,
+		s.turn,
+		cycle,
+		false,
+	}
+	s.keyPressMtx.Lock()
+	if s.stepping {
+		s.stepping = false
+		s.steppedTurn <- s.turn
 	}
-	keyPressMtx.Lock()
-	//countAliveCellsMtx.Lock()
-	pausing = false
-	turn = 0
-	// countAliveCellsMtx.Unlock()
-	keyPressMtx.Unlock()
+	s.keyPressMtx.Unlock()
 
 	return controlerResponse
 }
 
-// PRC for runGameBrokerCall
+// RPC for StartSession, starts the session the other RPCs of a controller are for
+func (c *Controler) StartSession_RPC(controlerRequest struct{}, controlerResponse *int) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s := startSession()
+	go s.endUnstartedOnClose(c.closed)
+	*controlerResponse = s.id
+	return nil
+}
+
+// RPC for Sessions, lists the sessions so that runs left behind by their controller can be found
+func (c *Controler) Sessions_RPC(controlerRequest struct{}, controlerResponse *[]gol.SessionInfo) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	*controlerResponse = listSessions()
+	return nil
+}
+
+// PRC for runGameBrokerCall, returns once the run has finished or the controller detached from it
 func (c *Controler) RunGameBrokerCall_RPC(controlerRequest gol.Request, controlerResponse *gol.FinalResponse) error {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
-	*controlerResponse = runGameBrokerCall(controlerRequest)
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	// Parse the rule once per run, the nodes only receive the parsed form
+	rule, err := gol.ParseRule(controlerRequest.Parameters.Rule)
+	if err != nil {
+		s.end()
+		return err
+	}
+	monitor, err := gol.NewMonitor(controlerRequest.Parameters)
+	if err != nil {
+		s.end()
+		return err
+	}
+	s.keyPressMtx.Lock()
+	s.params = controlerRequest.Parameters
+	s.running = true
+	s.keyPressMtx.Unlock()
+
+	// The run goes on if the controller detaches, a finished run nobody is attached to is kept for a while
+	go func() {
+		s.final = s.runGame(controlerRequest, rule, monitor)
+		s.keyPressMtx.Lock()
+		close(s.done)
+		if !s.attached {
+			s.expireUnlessAttached(finishedTimeout, s.end)
+		}
+		s.keyPressMtx.Unlock()
+	}()
+	*controlerResponse = s.waitOnConn(c.closed)
+	return nil
+}
+
+// RPC for EndSession, ends the session once the controller has fetched the final response, snapshots and nodes lost
+func (c *Controler) EndSession_RPC(controlerRequest gol.SessionRequest, controlerResponse *struct{}) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.end()
+	return nil
+}
+
+// RPC for WaitBroker, waits like runGameBrokerCall for the run a controller attached to
+func (c *Controler) WaitBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.FinalResponse) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	*controlerResponse = s.waitOnConn(c.closed)
+	return nil
+}
+
+// RPC for DetachBroker, detaches the controller of the session, leaving the run to go on
+func (c *Controler) DetachBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *struct{}) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.detach(false)
+	return nil
+}
+
+// RPC for SessionParams, returns the parameters of a session with the speed and recording its run has now
+func (c *Controler) SessionParams_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.Params) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.keyPressMtx.Lock()
+	*controlerResponse = s.params
+	controlerResponse.TurnsPerSecond = s.throttle.TurnsPerSecond
+	controlerResponse.Recording.Start = s.recording
+	s.keyPressMtx.Unlock()
+	return nil
+}
+
+// RPC for AttachBroker, attaches a controller to a detached session and returns the world its run has reached
+func (c *Controler) AttachBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.AttachResponse) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.keyPressMtx.Lock()
+	if s.attached {
+		s.keyPressMtx.Unlock()
+		return fmt.Errorf("session %v is already attached", s.id)
+	}
+	s.attached = true
+	s.detached = make(chan struct{})
+
+	// Snapshots left from before the controller detached are of turns before the world attached from
+	for len(s.snapshots) > 0 {
+		<-s.snapshots
+	}
+	paused := s.pausing
+	s.keyPressMtx.Unlock()
+
+	s.partMtx.Lock()
+	defer s.partMtx.Unlock()
+	if s.part != nil && s.partTurn == s.turn {
+		s.fetchWorld()
+	}
+	*controlerResponse = gol.AttachResponse{
+		s.currentWorld,
+		s.currentWorldTurn,
+		paused,
+	}
 	return nil
 }
 
 // RPC for CountAliveCells
-func (c *Controler) CountAliveCells_RPC(controlerRequest struct{}, controlerResponse *gol.AliveCellsCount) error {
+func (c *Controler) CountAliveCells_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.AliveCellsCount) error {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
-	countAliveCellsMtx.Lock()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.countAliveCellsMtx.Lock()
 	*controlerResponse = gol.AliveCellsCount{
-		turn,
-		currentAliveCellsCount,
+		s.turn,
+		s.currentAliveCellsCount,
 	}
-	countAliveCellsMtx.Unlock()
+	s.countAliveCellsMtx.Unlock()
 	return nil
 }
 
 // RPC for SaveCurrentWorld
-func (c *Controler) SaveCurrentWorld_RPC(controlerRequest struct{}, controlerResponse *gol.CurrentResponse) error {
+func (c *Controler) SaveCurrentWorld_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.CurrentResponse) error {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
-	keyPressMtx.Lock()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.partMtx.Lock()
+	if s.part != nil && s.partTurn == s.turn {
+		s.fetchWorld()
+	}
 	*controlerResponse = gol.CurrentResponse{
-		currentWorld,
-		turn,
+		s.currentWorld,
+		s.currentWorldTurn,
+		false,
+		false,
 	}
-	keyPressMtx.Unlock()
+	s.partMtx.Unlock()
 	return nil
 }
 
-// RPC for QuitBroker
-func (c *Controler) QuitBroker_RPC(controlerRequest struct{}, controlerResponse *struct{}) error {
+// RPC for NodeStatus, returns the nodes lost and rejoined during the session after the number the client has seen
+func (c *Controler) NodeStatus_RPC(controlerRequest gol.NodeStatusRequest, controlerResponse *[]gol.NodeStatus) error {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
-	quitting = true
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	statuses := registry.statusesFrom(s.statusesFrom + controlerRequest.Seen)
+	s.countAliveCellsMtx.Lock()
+	for i := range statuses {
+		statuses[i].CompletedTurns = s.turn
+	}
+	s.countAliveCellsMtx.Unlock()
+	*controlerResponse = statuses
 	return nil
 }
 
-// RPC for CloseBroker
-func (c *Controler) CloseBroker_RPC(controlerRequest struct{}, controlerResponse *struct{}) error {
+// RPC for NextSnapshot, waits for the next snapshot a trigger saved, checkpoint or frame of a recording. The world is nil once the run has finished or the controller detached.
+func (c *Controler) NextSnapshot_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.CurrentResponse) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	*controlerResponse = s.nextSnapshot()
+	return nil
+}
+
+// RPC for QuitBroker, quits the run of the session
+func (c *Controler) QuitBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *struct{}) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.quit()
+	return nil
+}
+
+// RPC for CloseBroker, quits the runs of every session and shuts the broker down
+func (c *Controler) CloseBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *struct{}) error {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
 	closing = true
@@ -217,22 +480,122 @@ func (c *Controler) CloseBroker_RPC(controlerRequest struct{}, controlerResponse
 }
 
 // RPC for PauseBroker
-func (c *Controler) PauseBroker_RPC(controlerRequest struct{}, controlerResponse *gol.PausingResponse) error {
+func (c *Controler) PauseBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.PausingResponse) error {
 	waitRPC.Add(1)
 	defer waitRPC.Done()
-	keyPressMtx.Lock()
-	pausing = !pausing
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.keyPressMtx.Lock()
+	s.pausing = !s.pausing
+	pauseTurn := s.turn
+	if s.pausing {
+		// A turn being run as the key was pressed is finished, the run pauses after it
+		if s.inFlight {
+			pauseTurn++
+		}
+		s.pausedAt = pauseTurn
+	}
 	*controlerResponse = gol.PausingResponse{
-		pausing,
-		turn,
+		s.pausing,
+		pauseTurn,
 	}
-	keyPressMtx.Unlock()
+	s.keyPressMtx.Unlock()
+	return nil
+}
+
+// RPC for StepBroker, runs a single turn while paused
+func (c *Controler) StepBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.PausingResponse) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.keyPressMtx.Lock()
+	if !s.pausing || s.stepping {
+		*controlerResponse = gol.PausingResponse{
+			s.pausing,
+			s.turn,
+		}
+		s.keyPressMtx.Unlock()
+		return nil
+	}
+	s.stepping = true
+	s.keyPressMtx.Unlock()
+	*controlerResponse = gol.PausingResponse{
+		true,
+		<-s.steppedTurn,
+	}
+	return nil
+}
+
+// RPC for SetSpeedBroker
+func (c *Controler) SetSpeedBroker_RPC(controlerRequest gol.SpeedRequest, controlerResponse *gol.SpeedChanged) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.keyPressMtx.Lock()
+	s.throttle.TurnsPerSecond = controlerRequest.TurnsPerSecond
+	*controlerResponse = gol.SpeedChanged{
+		s.turn,
+		controlerRequest.TurnsPerSecond,
+	}
+	s.keyPressMtx.Unlock()
+	return nil
+}
+
+// RPC for RecordBroker, starts or stops sending the frames of a recording
+func (c *Controler) RecordBroker_RPC(controlerRequest gol.SessionRequest, controlerResponse *gol.RecordingToggled) error {
+	waitRPC.Add(1)
+	defer waitRPC.Done()
+	s, err := findSession(controlerRequest.Session)
+	if err != nil {
+		return err
+	}
+	s.keyPressMtx.Lock()
+	s.recording = !s.recording
+	*controlerResponse = gol.RecordingToggled{
+		s.turn,
+		s.recording,
+	}
+	s.keyPressMtx.Unlock()
 	return nil
 }
 
 func main() {
+	nodesFlag := flag.String("nodes", "", "comma separated addresses of the nodes, such as 184.72.68.197:8080,44.206.242.85:8080")
+	nodesFile := flag.String("nodes-file", "", "file listing the addresses of the nodes, one per line")
+	p := flag.String("port", "8030", "port to listen on for clients and nodes registering")
+	flag.DurationVar(&callTimeout, "call-timeout", callTimeout, "how long a node may take to answer before it is taken to have failed")
+	flag.DurationVar(&redialTimeout, "redial-timeout", redialTimeout, "how long a node that failed is dialed again before it is given up on, until it registers again")
+	flag.DurationVar(&fetchInterval, "fetch-interval", fetchInterval, "longest time between fetching the world from the nodes, the turns since are run again if a node fails")
+	flag.DurationVar(&orphanTimeout, "orphan-timeout", orphanTimeout, "how long the run of a controller whose connection dropped is kept for one to attach before it is quit")
+	flag.DurationVar(&finishedTimeout, "finished-timeout", finishedTimeout, "how long a finished run no controller is attached to is kept for one to attach and fetch its final turn")
+	flag.Parse()
+
+	// Nodes listed are dialed in the background, others may register once the broker listens
+	addresses := parseNodes(*nodesFlag)
+	if *nodesFile != "" {
+		fileAddresses, err := readNodesFile(*nodesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		addresses = append(addresses, fileAddresses...)
+	}
+	for _, address := range addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			log.Fatalf("Node %q: %v", address, err)
+		}
+		registry.join(address)
+	}
+
 	// Listen for connections
-	ln, err := net.Listen("tcp", ":8030")
+	ln, err := net.Listen("tcp", ":"+*p)
 
 	if err != nil {
 		log.Fatal("Listening failed...")
@@ -241,21 +604,6 @@ func main() {
 		fmt.Println("Listening successed...")
 	}
 
-	for i := 0; i < 16; i++ {
-		for {
-			address := awsNodeIPs[(i+4)%4] + ":" + port[(i+4)%4]
-			broker, err := rpc.Dial("tcp", address)
-			if err == nil {
-				brokers[i] = broker
-				break
-			}
-		}
-	}
-
-	// Register Contro
-	Controler := new(Controler)
-	rpc.Register(Controler)
-
 	// Iteratelly connect to local controllers
 	for {
 		conn, err := ln.Accept()
@@ -264,8 +612,14 @@ func main() {
 			log.Fatal("Connection failed with client")
 		}
 
+		// Each connection has its own Controler for clients, so the RPCs of a run know when the connection drops,
+		// and a Broker for nodes
 		go func() {
-			rpc.ServeConn(conn)
+			conn := &controlerConn{Conn: conn, closed: make(chan struct{})}
+			server := rpc.NewServer()
+			server.Register(&Controler{conn.closed})
+			server.Register(new(Broker))
+			server.ServeConn(conn)
 			fmt.Println("Connection successed with client")
 
 		}()
@@ -280,9 +634,5 @@ func main() {
 	waitRPC.Wait()
 	fmt.Println("Closing gracefully the Broker")
 	//Close awsNodes connections
-	for _, broker := range brokers {
-		if broker != nil {
-			defer broker.Close()
-		}
-	}
+	registry.close()
 }
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// How long dialing a node may take, and the back-off between attempts to dial a node that is not up yet
+const dialTimeout = 2 * time.Second
+const minBackOff = 100 * time.Millisecond
+const maxBackOff = 10 * time.Second
+
+// How long a call to a node may take before the node is taken to have failed, set by the -call-timeout flag
+var callTimeout = 30 * time.Second
+
+// How long a node that failed is dialed again before it is given up on, set by the -redial-timeout flag. A node given
+// up on joins again by registering.
+var redialTimeout = 5 * time.Minute
+
+// A worker node the broker divides the turns between
+type node struct {
+	address string
+	client  *rpc.Client
+}
+
+// The nodes that are alive, listed by flag or file or registered over Broker.RegisterNode_RPC. Nodes that failed
+// are dialed again, and the nodes lost and rejoined are kept for the client to report.
+type nodeRegistry struct {
+	mtx      sync.Mutex
+	nodes    []*node
+	dialing  map[string]bool
+	retry    map[string]chan struct{}
+	lost     map[string]bool
+	statuses []gol.NodeStatus
+}
+
+var registry = nodeRegistry{dialing: make(map[string]bool), retry: make(map[string]chan struct{}), lost: make(map[string]bool)}
+
+// Return the nodes that are alive, in the order they joined
+func (r *nodeRegistry) alive() []*node {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]*node(nil), r.nodes...)
+}
+
+// Dial the node at address in the background, backing off while it is not up, and add it once it is. A node that is
+// already alive is not dialed again, one being dialed is dialed now rather than after the back-off.
+func (r *nodeRegistry) join(address string) {
+	r.dial(address, false)
+}
+
+// Dial the node at address in the background, as a node that failed if redial is set
+func (r *nodeRegistry) dial(address string, redial bool) {
+	r.mtx.Lock()
+	if r.dialing[address] {
+		select {
+		case r.retry[address] <- struct{}{}:
+		default:
+		}
+		r.mtx.Unlock()
+		return
+	}
+	retry := make(chan struct{}, 1)
+	r.dialing[address] = true
+	r.retry[address] = retry
+	r.mtx.Unlock()
+
+	go func() {
+		client := dialNode(address, retry, redial)
+		r.mtx.Lock()
+		delete(r.retry, address)
+		if client == nil {
+			delete(r.dialing, address)
+			r.mtx.Unlock()
+			fmt.Println("Node given up", address)
+			return
+		}
+		r.nodes = append(r.nodes, &node{address, client})
+		if r.lost[address] {
+			delete(r.lost, address)
+			r.statuses = append(r.statuses, gol.NodeStatus{Address: address, Lost: false})
+		}
+		r.mtx.Unlock()
+		fmt.Println("Node joined", address)
+	}()
+}
+
+// Remove a node that failed a call, so the next turns are divided between the nodes left, and dial it again
+func (r *nodeRegistry) remove(failed *node) {
+	r.mtx.Lock()
+	for i, n := range r.nodes {
+		if n == failed {
+			r.nodes = append(r.nodes[:i], r.nodes[i+1:]...)
+			delete(r.dialing, n.address)
+			r.lost[n.address] = true
+			r.statuses = append(r.statuses, gol.NodeStatus{Address: n.address, Lost: true})
+			n.client.Close()
+			r.mtx.Unlock()
+			fmt.Println("Node lost", n.address)
+			r.dial(n.address, true)
+			return
+		}
+	}
+	r.mtx.Unlock()
+}
+
+// Return how many times nodes were lost and rejoined, so a session can tell which happened during it
+func (r *nodeRegistry) statusCount() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.statuses)
+}
+
+// Return the nodes lost and rejoined from the first one given on
+func (r *nodeRegistry) statusesFrom(first int) []gol.NodeStatus {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if first >= len(r.statuses) {
+		return nil
+	}
+	return append([]gol.NodeStatus(nil), r.statuses[first:]...)
+}
+
+// Close the connections to all nodes
+func (r *nodeRegistry) close() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, n := range r.nodes {
+		n.client.Close()
+	}
+	r.nodes = nil
+}
+
+// Call a method of a node, which fails if the node does not answer within callTimeout. A node that fails is removed.
+func (n *node) call(method string, args interface{}, reply interface{}) error {
+	var err error
+	select {
+	case call := <-n.client.Go(method, args, reply, make(chan *rpc.Call, 1)).Done:
+		err = call.Error
+	case <-time.After(callTimeout):
+		err = fmt.Errorf("no answer within %v", callTimeout)
+	}
+	if err != nil {
+		log.Printf("Node %v failed: %v", n.address, err)
+		registry.remove(n)
+	}
+	return err
+}
+
+// Dial a node until it answers, with a timeout on each attempt and an exponential back-off between them, which a send
+// on retry cuts short. A node dialed again after it failed may still be going down, so it is only dialed after the
+// first back-off, and it is given up on, returning nil, once it has not answered for redialTimeout.
+func dialNode(address string, retry <-chan struct{}, redial bool) *rpc.Client {
+	backOff := minBackOff
+	wait := func() {
+		select {
+		case <-time.After(backOff):
+		case <-retry:
+		}
+		if backOff *= 2; backOff > maxBackOff {
+			backOff = maxBackOff
+		}
+	}
+	deadline := time.Now().Add(redialTimeout)
+	if redial {
+		wait()
+	}
+	for {
+		conn, err := net.DialTimeout("tcp", address, dialTimeout)
+		if err == nil {
+			return rpc.NewClient(conn)
+		}
+		if redial && time.Now().After(deadline) {
+			log.Printf("Dialing node %v failed for %v, giving up: %v", address, redialTimeout, err)
+			return nil
+		}
+		log.Printf("Dialing node %v failed, retrying in %v: %v", address, backOff, err)
+		wait()
+	}
+}
+
+// Parse a comma separated list of node addresses, such as "10.0.0.1:8080,10.0.0.2:8080"
+func parseNodes(list string) []string {
+	var addresses []string
+	for _, address := range strings.Split(list, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// Read the node addresses of a file, one per line. Blank lines and lines starting with # are skipped.
+func readNodesFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var addresses []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(line); err != nil {
+			return nil, fmt.Errorf("%v: node %q: %v", filename, line, err)
+		}
+		addresses = append(addresses, line)
+	}
+	return addresses, scanner.Err()
+}
+
+// Broker serves the nodes, which register with it to be given work
+type Broker struct{}
+
+// RPC for RegisterNode, adds the node listening on the address to the registry
+func (b *Broker) RegisterNode_RPC(address string, registered *bool) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("node %q: %v", address, err)
+	}
+	registry.join(address)
+	*registered = true
+	return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// A session is the run of one controller. Sessions run at the same time, dividing their turns between the
+// same nodes, and each control RPC names the session it is for. The controller may detach and another attach later,
+// the run goes on meanwhile. The strips and the world fetched are kept holding partMtx, the controls holding
+// keyPressMtx, and the turn and world fetched are only changed holding both.
+type session struct {
+	id                     int
+	params                 gol.Params
+	running                bool // whether the run has started
+	attached               bool
+	detached               chan struct{} // closed when the controller attached detaches
+	done                   chan struct{} // closed when the run has finished, with its final response
+	final                  gol.FinalResponse
+	turn                   int
+	pausing                bool
+	pausedAt               int  // turn the run pauses at, after a turn being run as it was paused
+	inFlight               bool // whether a turn after turn is being run
+	quitting               bool
+	stepping               bool
+	recording              bool
+	throttle               gol.Throttle
+	currentWorld           [][]uint8 // last world fetched whole, which is never changed
+	currentWorldTurn       int
+	fetchedAt              time.Time
+	part                   *partition // strips of the world the nodes keep, at turn partTurn
+	partTurn               int
+	currentAliveCellsCount int
+	statusesFrom           int // first of the nodes lost and rejoined during the session
+	steppedTurn            chan int
+	snapshots              chan gol.CurrentResponse
+	partMtx                sync.Mutex
+	keyPressMtx            sync.Mutex
+	countAliveCellsMtx     sync.Mutex
+}
+
+// How long detaching waits for the world to be fetched
+const detachTimeout = time.Second
+
+// How long the run of a controller whose connection dropped is kept for one to attach before it is quit, set by the
+// -orphan-timeout flag, and how long a finished run is kept for a controller to fetch it, set by -finished-timeout
+var orphanTimeout = 10 * time.Minute
+var finishedTimeout = time.Hour
+
+var sessions = make(map[int]*session)
+var sessionsMtx sync.Mutex
+var nextSession = 1
+
+// Start a session, which lasts until the controller attached to it ends it with the final response of its run
+func startSession() *session {
+	sessionsMtx.Lock()
+	defer sessionsMtx.Unlock()
+	s := &session{
+		id:           nextSession,
+		attached:     true,
+		detached:     make(chan struct{}),
+		done:         make(chan struct{}),
+		statusesFrom: registry.statusCount(),
+		steppedTurn:  make(chan int, 1),
+		snapshots:    make(chan gol.CurrentResponse, 16),
+	}
+	sessions[s.id] = s
+	nextSession++
+	return s
+}
+
+// Look up a session that has not ended
+func findSession(id int) (*session, error) {
+	sessionsMtx.Lock()
+	defer sessionsMtx.Unlock()
+	s, ok := sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %v not found", id)
+	}
+	return s, nil
+}
+
+// End a session once the controller attached has fetched all of its run
+func (s *session) end() {
+	sessionsMtx.Lock()
+	defer sessionsMtx.Unlock()
+	delete(sessions, s.id)
+}
+
+// List the sessions in the order they started
+func listSessions() []gol.SessionInfo {
+	sessionsMtx.Lock()
+	var list []*session
+	for _, s := range sessions {
+		list = append(list, s)
+	}
+	sessionsMtx.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+
+	var infos []gol.SessionInfo
+	for _, s := range list {
+		s.keyPressMtx.Lock()
+		finished := false
+		select {
+		case <-s.done:
+			finished = true
+		default:
+		}
+		infos = append(infos, gol.SessionInfo{s.id, s.running, s.attached, finished, s.turn, s.params.Turns, s.params.ImageWidth, s.params.ImageHeight})
+		s.keyPressMtx.Unlock()
+	}
+	return infos
+}
+
+// End the session if the connection it was started on drops before its run starts, no controller could attach to it
+func (s *session) endUnstartedOnClose(closed <-chan struct{}) {
+	<-closed
+	s.keyPressMtx.Lock()
+	running := s.running
+	s.keyPressMtx.Unlock()
+	if !running {
+		s.end()
+	}
+}
+
+// Quit the run of the session
+func (s *session) quit() {
+	s.keyPressMtx.Lock()
+	s.quitting = true
+	s.keyPressMtx.Unlock()
+}
+
+// Detach the controller attached to the session, leaving the run to go on. The run of a controller whose connection
+// dropped is quit unless one attaches within orphanTimeout, and a finished run is ended unless one attaches within
+// finishedTimeout.
+func (s *session) detach(dropped bool) {
+	s.keyPressMtx.Lock()
+	defer s.keyPressMtx.Unlock()
+	if !s.attached {
+		return
+	}
+	s.attached = false
+	close(s.detached)
+	select {
+	case <-s.done:
+		s.expireUnlessAttached(finishedTimeout, s.end)
+	default:
+		if dropped {
+			s.expireUnlessAttached(orphanTimeout, s.quit)
+		}
+	}
+}
+
+// Quit or end the session with action after a while, unless a controller attaches to it meanwhile. It is called
+// holding keyPressMtx while no controller is attached.
+func (s *session) expireUnlessAttached(after time.Duration, action func()) {
+	detached := s.detached
+	time.AfterFunc(after, func() {
+		s.keyPressMtx.Lock()
+		expired := !s.attached && s.detached == detached
+		s.keyPressMtx.Unlock()
+		if expired {
+			log.Printf("Session %v had no controller attached for %v", s.id, after)
+			action()
+		}
+	})
+}
+
+// Deal the strips to the nodes again if they changed. Unless a node was lost the strips are fetched first, so the
+// turns they ran are kept, otherwise they are dealt from the last world fetched and the turns since are run again.
+func (s *session) dealStrips(nodes []*node, threads int, rule gol.Rule, boundary gol.Boundary) bool {
+	if s.part != nil && s.part.current(nodes) {
+		return true
+	}
+	if s.part != nil {
+		s.fetchWorld()
+	}
+	s.dropStrips()
+	var err error
+	if s.part, err = newPartition(s.currentWorld, threads, nodes, rule, boundary); err != nil {
+		s.nodeLost()
+		return false
+	}
+	s.partTurn = s.currentWorldTurn
+	return true
+}
+
+// Fetch the world from the strips. It is only kept if every strip was fetched, so it is always a world of the run.
+// It is called holding partMtx but not keyPressMtx, like the other methods changing the strips.
+func (s *session) fetchWorld() bool {
+	world, err := s.part.fetch()
+	if err != nil {
+		s.nodeLost()
+		return false
+	}
+	s.keyPressMtx.Lock()
+	s.currentWorld, s.currentWorldTurn = world, s.partTurn
+	s.keyPressMtx.Unlock()
+	s.fetchedAt = time.Now()
+	return true
+}
+
+// Drop the strips after a node was lost, they are dealt again from the last world fetched
+func (s *session) nodeLost() {
+	s.dropStrips()
+	s.partTurn = s.currentWorldTurn
+}
+
+// Drop the strips the nodes keep for the session, if it has any
+func (s *session) dropStrips() {
+	if s.part != nil {
+		s.part.drop()
+		s.part = nil
+	}
+}
+
+// Wait for the run to finish, or for the controller to detach, when the response is the last world fetched and the
+// session is kept for a controller to attach to later
+func (s *session) wait() gol.FinalResponse {
+	s.keyPressMtx.Lock()
+	detached := s.detached
+	s.keyPressMtx.Unlock()
+	select {
+	case <-s.done:
+		return s.final
+	case <-detached:
+		// The world is fetched for the controller to output, but detaching does not wait long for a node that is
+		// failing, the last world fetched is output instead
+		fetched := make(chan struct{})
+		go func() {
+			s.partMtx.Lock()
+			if s.part != nil && s.partTurn == s.turn {
+				s.fetchWorld()
+			}
+			s.partMtx.Unlock()
+			close(fetched)
+		}()
+		select {
+		case <-fetched:
+		case <-time.After(detachTimeout):
+		}
+		s.keyPressMtx.Lock()
+		defer s.keyPressMtx.Unlock()
+		return gol.FinalResponse{s.currentWorld, nil, s.currentWorldTurn, gol.CycleDetected{}, true}
+	}
+}
+
+// Wait like wait for the controller on a connection. A controller that is gone cannot detach itself, so it is
+// detached if the connection drops first.
+func (s *session) waitOnConn(closed <-chan struct{}) gol.FinalResponse {
+	returned := make(chan struct{})
+	defer close(returned)
+	go func() {
+		select {
+		case <-closed:
+			log.Printf("Session %v lost the connection of its controller, detaching it", s.id)
+			s.detach(true)
+		case <-returned:
+		}
+	}()
+	response := s.wait()
+	if !response.Detached {
+		// The controller ends the session once it has fetched the rest of the run, unless it is gone before
+		time.AfterFunc(finishedTimeout, s.end)
+	}
+	return response
+}
+
+// Send a snapshot to the controller, it is dropped if the controller detached as no one fetches it
+func (s *session) sendSnapshot(snapshot gol.CurrentResponse, detached chan struct{}) {
+	select {
+	case s.snapshots <- snapshot:
+	case <-detached:
+	}
+}
+
+// Receive the next snapshot, or one with a nil world once the run has finished or the controller detached
+func (s *session) nextSnapshot() gol.CurrentResponse {
+	s.keyPressMtx.Lock()
+	detached, turn := s.detached, s.turn
+	s.keyPressMtx.Unlock()
+	select {
+	case snapshot := <-s.snapshots:
+		return snapshot
+	case <-s.done:
+		// Every snapshot was sent before the run finished
+		select {
+		case snapshot := <-s.snapshots:
+			return snapshot
+		default:
+			return gol.CurrentResponse{nil, s.final.CompleteTurns, false, false}
+		}
+	case <-detached:
+		return gol.CurrentResponse{nil, turn, false, false}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Request to a node to keep a strip of rows of the world between turns
+type StripRequest struct {
+	Strip       int
+	StartY      int
+	EndY        int
+	Rows        [][]uint8
+	WorldHeight int
+	WorldWidth  int
+	Rule        gol.Rule
+	Boundary    gol.Boundary
+}
+
+// Request to a node to run a turn of a strip, given the rows around it it reads, indexed by their row in the world
+type StepRequest struct {
+	Strip int
+	Halo  map[int][]uint8
+	Hash  bool
+}
+
+// The rows of a strip its neighbours read, indexed by their row in the world, after a turn
+type StepResponse struct {
+	Edges           map[int][]uint8
+	AliveCellsCount int
+	Hash            uint64
+}
+
+// The rows of a strip, fetched when the broker needs the whole world
+type StripRows struct {
+	Rows [][]uint8
+}
+
+// Strips are numbered across all sessions, as the nodes keep the strips of every session
+var nextStrip int64
+
+// A strip of rows of the world, kept by a node between turns
+type strip struct {
+	node   *node
+	index  int
+	startY int
+	endY   int
+}
+
+// The strips of the run, the rows each strip reads of its neighbours and how many rows deep they are
+type partition struct {
+	strips []strip
+	nodes  []*node
+	edges  map[int][]uint8
+	depth  int
+	height int
+	width  int
+	rule   gol.Rule
+	bound  gol.Boundary
+}
+
+// How many rows around a strip its cells read, which is the range of Larger than Life rules
+func haloDepth(rule gol.Rule) int {
+	if rule.IsLargerThanLife() {
+		return rule.Range
+	}
+	return 1
+}
+
+// Divide world into threads strips, dealt to the nodes in turn, and load each on its node. It fails if a node does.
+func newPartition(world [][]uint8, threads int, nodes []*node, rule gol.Rule, boundary gol.Boundary) (*partition, error) {
+	height := len(world)
+	part := &partition{
+		nodes:  nodes,
+		edges:  make(map[int][]uint8),
+		depth:  haloDepth(rule),
+		height: height,
+		width:  len(world[0]),
+		rule:   rule,
+		bound:  boundary,
+	}
+	unitY := height / threads
+	for i := 0; i < threads; i++ {
+		endY := unitY * (i + 1)
+		if i == threads-1 {
+			endY = height
+		}
+		part.strips = append(part.strips, strip{nodes[i%len(nodes)], int(atomic.AddInt64(&nextStrip, 1)), unitY * i, endY})
+	}
+
+	errs := make([]error, len(part.strips))
+	var wg sync.WaitGroup
+	for i, s := range part.strips {
+		for y := range part.edgeRows(s) {
+			part.edges[y] = world[y]
+		}
+		wg.Add(1)
+		go func(i int, s strip) {
+			defer wg.Done()
+			request := StripRequest{s.index, s.startY, s.endY, world[s.startY:s.endY], height, part.width, rule, boundary}
+			errs[i] = s.node.call("Broker.LoadStrip_RPC", request, &struct{}{})
+		}(i, s)
+	}
+	wg.Wait()
+	return part, firstError(errs)
+}
+
+// The first error that is not nil, if any
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The rows of s within depth of its top and bottom, which its neighbours read
+func (part *partition) edgeRows(s strip) map[int]bool {
+	rows := make(map[int]bool)
+	for d := 0; d < part.depth && d < s.endY-s.startY; d++ {
+		rows[s.startY+d] = true
+		rows[s.endY-1-d] = true
+	}
+	return rows
+}
+
+// The rows within depth above and below s, wrapping around the world, which s reads
+func (part *partition) halo(s strip) map[int][]uint8 {
+	halo := make(map[int][]uint8)
+	for d := 1; d <= part.depth; d++ {
+		for _, y := range []int{s.startY - d, s.endY - 1 + d} {
+			y = ((y % part.height) + part.height) % part.height
+			if y < s.startY || y >= s.endY {
+				halo[y] = part.edges[y]
+			}
+		}
+	}
+	return halo
+}
+
+// Run a turn on every strip, exchanging only the rows around them, and return the alive cells and hash of the world.
+// It fails if a node does, when the strips are no longer all at the same turn.
+func (part *partition) step(hash bool) (aliveCells int, worldHash uint64, err error) {
+	responses := make([]StepResponse, len(part.strips))
+	errs := make([]error, len(part.strips))
+	var wg sync.WaitGroup
+	for i, s := range part.strips {
+		wg.Add(1)
+		go func(i int, s strip) {
+			defer wg.Done()
+			errs[i] = s.node.call("Broker.StepStrip_RPC", StepRequest{s.index, part.halo(s), hash}, &responses[i])
+		}(i, s)
+	}
+	wg.Wait()
+	if err := firstError(errs); err != nil {
+		return 0, 0, err
+	}
+
+	// The halos of the next turn are taken from the edges each strip returned
+	for _, response := range responses {
+		for y, row := range response.Edges {
+			part.edges[y] = row
+		}
+		aliveCells += response.AliveCellsCount
+		worldHash ^= response.Hash
+	}
+	return aliveCells, worldHash, nil
+}
+
+// Fetch the rows of every strip as a new world. It fails if a node does.
+func (part *partition) fetch() ([][]uint8, error) {
+	world := make([][]uint8, part.height)
+	errs := make([]error, len(part.strips))
+	var wg sync.WaitGroup
+	for i, s := range part.strips {
+		wg.Add(1)
+		go func(i int, s strip) {
+			defer wg.Done()
+			var rows StripRows
+			if errs[i] = s.node.call("Broker.FetchStrip_RPC", s.index, &rows); errs[i] == nil {
+				copy(world[s.startY:s.endY], rows.Rows)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+	return world, firstError(errs)
+}
+
+// Tell the nodes to drop the strips, which they are no longer given turns of
+func (part *partition) drop() {
+	indices := make(map[*node][]int)
+	for _, s := range part.strips {
+		indices[s.node] = append(indices[s.node], s.index)
+	}
+	for n, strips := range indices {
+		go n.call("Broker.DropStrips_RPC", strips, &struct{}{})
+	}
+}
+
+// Whether the strips are still dealt to exactly the nodes that are alive
+func (part *partition) current(nodes []*node) bool {
+	if len(nodes) != len(part.nodes) {
+		return false
+	}
+	for i := range nodes {
+		if nodes[i] != part.nodes[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -65,7 +65,7 @@ func TestAlive(t *testing.T) {
 				i++
 
 				if i >= 5 {
-					keyPresses <- 'q'
+					keyPresses <- 'x'
 					return
 				}
 			}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestDetectFormat tests that pattern formats are told apart by extension first and by contents otherwise.
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path     string
+		data     string
+		expected gol.Format
+	}{
+		{"glider.rle", "!Name: not cells", gol.RLE},
+		{"glider.cells", "x = 3, y = 3", gol.Cells},
+		{"glider.LIF", "", gol.Life106},
+		{"glider.life", "", gol.Life106},
+		{"glider", "#Life 1.06\n0 0\n", gol.Life106},
+		{"glider", "!Name: Glider\n.O.\n", gol.Cells},
+		{"glider", ".O.\n..O\nOOO\n", gol.Cells},
+		{"glider", "#N Glider\nx = 3, y = 3\nbob$2bo$3o!", gol.RLE},
+		{"glider", "x = 3, y = 3\nbob$2bo$3o!", gol.RLE},
+		{"16x16", "P2\n16 16\n255\n", gol.PGM},
+	}
+	for _, test := range tests {
+		if format := gol.DetectFormat(test.path, []byte(test.data)); format != test.expected {
+			t.Errorf("%v holding %q detected as %v, expected %v", test.path, test.data, format, test.expected)
+		}
+	}
+}
+
+// TestReadFormats tests that the same glider is read from each pattern format.
+func TestReadFormats(t *testing.T) {
+	tests := map[string]func() (gol.Pattern, error){
+		"cells": func() (gol.Pattern, error) {
+			return gol.ReadCells(strings.NewReader("!Name: Glider\n!\n.O\n..O\nOOO\n\n"))
+		},
+		"life": func() (gol.Pattern, error) {
+			return gol.ReadLife106(strings.NewReader("#Life 1.06\n#D Glider\n#D The smallest spaceship.\n0 -1\n1 0\n-1 1\n0 1\n1 1\n"))
+		},
+	}
+	expected := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	for name, read := range tests {
+		pattern, err := read()
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if pattern.Width != 3 || pattern.Height != 3 || len(pattern.Comments) != 2 {
+			t.Errorf("%v: read %vx%v pattern with comments %q", name, pattern.Width, pattern.Height, pattern.Comments)
+		}
+		world, _ := pattern.World(3, 3, gol.Conway)
+		assertEqualBoard(t, aliveInWorld(world), expected, gol.Params{ImageWidth: 3, ImageHeight: 3})
+	}
+
+	for _, broken := range []string{".O.\n.X.\n"} {
+		if _, err := gol.ReadCells(strings.NewReader(broken)); err == nil {
+			t.Errorf("cells %q accepted", broken)
+		}
+	}
+	for _, broken := range []string{"0 0\n", "#Life 1.06\n0\n", "#Life 1.06\na b\n"} {
+		if _, err := gol.ReadLife106(strings.NewReader(broken)); err == nil {
+			t.Errorf("life %q accepted", broken)
+		}
+	}
+}
+
+// translate moves cells so that the smallest x and y are 0.
+func translate(cells []util.Cell) []util.Cell {
+	if len(cells) == 0 {
+		return cells
+	}
+	min := cells[0]
+	for _, cell := range cells {
+		if cell.X < min.X {
+			min.X = cell.X
+		}
+		if cell.Y < min.Y {
+			min.Y = cell.Y
+		}
+	}
+	moved := make([]util.Cell, len(cells))
+	for i, cell := range cells {
+		moved[i] = util.Cell{X: cell.X - min.X, Y: cell.Y - min.Y}
+	}
+	return moved
+}
+
+// TestOutputFormats tests that the final world is output in the chosen format and reads back the same.
+func TestOutputFormats(t *testing.T) {
+	for _, format := range []gol.Format{gol.RLE, gol.Cells, gol.Life106} {
+		t.Run(format.String(), func(t *testing.T) {
+			emptyOutFolder()
+			p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4, OutputFormat: format}
+			_, final := runAlive(p)
+			expected := readAliveCells("check/images/16x16x100.pgm", 16, 16)
+			assertEqualBoard(t, final.Alive, expected, p)
+
+			pattern, err := gol.ReadPatternFile(fmt.Sprintf("out/16x16x100%v", format.Extension()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			world, err := pattern.World(pattern.Width, pattern.Height, gol.Conway)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Life 1.06 patterns only span their cells, so compare the shapes
+			assertEqualBoard(t, translate(aliveInWorld(world)), translate(expected), p)
+		})
+	}
+
+	var buffer bytes.Buffer
+	if err := gol.WritePattern(&buffer, [][]uint8{{255}}, gol.Conway, gol.PGM); err == nil {
+		t.Error("pgm written as a pattern")
+	}
+}
@@ -0,0 +1,145 @@
+package gol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Boundary selects what lies beyond the edges of the world.
+type Boundary int
+
+const (
+	// Torus wraps around both axes. It is the default.
+	Torus Boundary = iota
+	// Dead treats every cell outside the world as dead.
+	Dead
+	// Reflect mirrors the world at its edges, the cell beyond an edge is the one next to it.
+	Reflect
+	// Klein wraps around both axes, flipping the x coordinate when wrapping vertically.
+	Klein
+	// CylinderX wraps around horizontally, the top and bottom edges are dead.
+	CylinderX
+	// CylinderY wraps around vertically, the left and right edges are dead.
+	CylinderY
+	// Unbounded has no edges, the world grows in every direction as far as its cells reach.
+	// Only the parallel implementation supports it, with a sparse world. Within a fixed-size
+	// world it behaves like Dead.
+	Unbounded
+)
+
+var boundaryNames = map[Boundary]string{
+	Torus:     "torus",
+	Dead:      "dead",
+	Reflect:   "reflect",
+	Klein:     "klein",
+	CylinderX: "cylinder-x",
+	CylinderY: "cylinder-y",
+	Unbounded: "unbounded",
+}
+
+// ParseBoundary parses a boundary mode by name: torus, dead, reflect, klein, cylinder-x, cylinder-y or unbounded.
+// An empty name parses to Torus.
+func ParseBoundary(s string) (Boundary, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return Torus, nil
+	}
+	for boundary, name := range boundaryNames {
+		if name == s {
+			return boundary, nil
+		}
+	}
+	return Torus, fmt.Errorf("boundary %q: expected one of torus, dead, reflect, klein, cylinder-x, cylinder-y or unbounded", s)
+}
+
+func (b Boundary) String() string {
+	if name, ok := boundaryNames[b]; ok {
+		return name
+	}
+	return "Incorrect Boundary"
+}
+
+// Cell returns the value of the cell at (x, y), which may lie outside the world.
+func (b Boundary) Cell(world [][]uint8, x, y int) uint8 {
+	height := len(world)
+	width := len(world[0])
+	if x >= 0 && x < width && y >= 0 && y < height {
+		return world[y][x]
+	}
+	switch b {
+	case Dead, Unbounded:
+		return 0
+	case Reflect:
+		x, y = reflect(x, width), reflect(y, height)
+	case Klein:
+		if floorDiv(y, height)%2 != 0 {
+			x = width - 1 - x
+		}
+		x, y = wrap(x, width), wrap(y, height)
+	case CylinderX:
+		if y < 0 || y >= height {
+			return 0
+		}
+		x = wrap(x, width)
+	case CylinderY:
+		if x < 0 || x >= width {
+			return 0
+		}
+		y = wrap(y, height)
+	default:
+		x, y = wrap(x, width), wrap(y, height)
+	}
+	return world[y][x]
+}
+
+// row maps a row y, which may lie outside the world, to the row of the world seen there.
+// flip is set if that row is seen mirrored and dead if every cell there is dead.
+func (b Boundary) row(y, height int) (row int, flip, dead bool) {
+	if y >= 0 && y < height {
+		return y, false, false
+	}
+	switch b {
+	case Dead, CylinderX, Unbounded:
+		return 0, false, true
+	case Reflect:
+		return reflect(y, height), false, false
+	case Klein:
+		return wrap(y, height), floorDiv(y, height)%2 != 0, false
+	default:
+		return wrap(y, height), false, false
+	}
+}
+
+func wrap(i, n int) int {
+	return ((i % n) + n) % n
+}
+
+func floorDiv(i, n int) int {
+	if i < 0 {
+		return -((n - 1 - i) / n)
+	}
+	return i / n
+}
+
+// reflect mirrors i back into 0..n-1, so -1 maps to 0 and n maps to n-1.
+func reflect(i, n int) int {
+	i = wrap(i, 2*n)
+	if i >= n {
+		return 2*n - 1 - i
+	}
+	return i
+}
+
+// Neighbours returns the number of alive cells among the 8 neighbours of (x, y).
+// Workers use it for cells on the edges of the world, where the boundary matters.
+func (b Boundary) Neighbours(world [][]uint8, x, y int) int {
+	neighbours := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx != 0 || dy != 0 {
+				neighbours += int(b.Cell(world, x+dx, y+dy) / 255)
+			}
+		}
+	}
+	return neighbours
+}
@@ -0,0 +1,167 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// checkpointMagic starts every checkpoint file, followed by the version of the format.
+const checkpointMagic = "GOL checkpoint 1"
+
+// Checkpoint is everything needed to continue a run after the turn it was written on. No rule or
+// engine draws random numbers, so a run continued from it is the same as one that never stopped.
+// It is stored as a text header of one field per line, followed by the cells of the world as
+// bytes in the same order as a PGM image:
+//
+//	GOL checkpoint 1
+//	turn 1000
+//	rule B3/S23
+//	boundary torus
+//	origin 0 0
+//	size 512 512
+type Checkpoint struct {
+	Turn     int
+	Rule     string
+	Boundary Boundary
+	Origin   util.Cell // position of the top left cell of World, which only moves for Unbounded worlds
+	World    [][]uint8
+}
+
+// CheckpointPath returns where the checkpoints of a run are written, each replacing the last.
+func CheckpointPath(p Params) string {
+	return fmt.Sprintf("out/%vx%v.checkpoint", p.ImageWidth, p.ImageHeight)
+}
+
+// Apply returns p set up to continue the run of the checkpoint, with its rule, boundary and size.
+func (c Checkpoint) Apply(p Params) Params {
+	p.Rule = c.Rule
+	p.Boundary = c.Boundary
+	if p.Boundary != Unbounded {
+		p.ImageHeight = len(c.World)
+		if len(c.World) > 0 {
+			p.ImageWidth = len(c.World[0])
+		}
+	}
+	return p
+}
+
+// WriteCheckpoint writes c to path. It is written to a temporary file first and renamed over path,
+// so path always holds a complete checkpoint even if the process dies while writing.
+func WriteCheckpoint(path string, c Checkpoint) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	width := 0
+	if len(c.World) > 0 {
+		width = len(c.World[0])
+	}
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, checkpointMagic)
+	fmt.Fprintln(writer, "turn", c.Turn)
+	fmt.Fprintln(writer, "rule", c.Rule)
+	fmt.Fprintln(writer, "boundary", c.Boundary)
+	fmt.Fprintln(writer, "origin", c.Origin.X, c.Origin.Y)
+	fmt.Fprintln(writer, "size", width, len(c.World))
+	for _, row := range c.World {
+		writer.Write(row)
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint.
+func ReadCheckpoint(path string) (Checkpoint, error) {
+	var c Checkpoint
+	file, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	line, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != checkpointMagic {
+		return c, fmt.Errorf("%v is not a checkpoint", path)
+	}
+	width, height := -1, -1
+	for height < 0 {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return c, fmt.Errorf("checkpoint %v: header ends early", path)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		values := fields[1:]
+		switch fields[0] {
+		case "turn":
+			err = checkpointInts(values, &c.Turn)
+		case "rule":
+			c.Rule = strings.Join(values, " ")
+		case "boundary":
+			c.Boundary, err = ParseBoundary(strings.Join(values, " "))
+		case "origin":
+			err = checkpointInts(values, &c.Origin.X, &c.Origin.Y)
+		case "size":
+			err = checkpointInts(values, &width, &height)
+			if err == nil && (width < 0 || height < 0) {
+				err = fmt.Errorf("negative size")
+			}
+		default:
+			// Fields added by later versions are skipped
+		}
+		if err != nil {
+			return c, fmt.Errorf("checkpoint %v: %v: %v", path, fields[0], err)
+		}
+	}
+	if _, err = ParseRule(c.Rule); err != nil {
+		return c, fmt.Errorf("checkpoint %v: %v", path, err)
+	}
+
+	c.World = make([][]uint8, height)
+	for y := range c.World {
+		c.World[y] = make([]uint8, width)
+		if _, err = io.ReadFull(reader, c.World[y]); err != nil {
+			return c, fmt.Errorf("checkpoint %v: world ends after %v of %v rows", path, y, height)
+		}
+	}
+	return c, nil
+}
+
+// checkpointInts parses values into targets, one each.
+func checkpointInts(values []string, targets ...*int) error {
+	if len(values) != len(targets) {
+		return fmt.Errorf("expected %v values", len(targets))
+	}
+	for i, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*targets[i] = n
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package gol
+
+// CycleDetector remembers the hashes of the last few generations of a world, so it can
+// tell when a generation repeats an earlier one. Dead and still worlds repeat with period 1.
+type CycleDetector struct {
+	history int
+	seen    map[uint64]int // hash of a generation to the turn it was first seen
+	hashes  []uint64       // hashes in the order they were seen, oldest first
+}
+
+// NewCycleDetector returns a detector that finds cycles with periods up to history turns.
+func NewCycleDetector(history int) *CycleDetector {
+	return &CycleDetector{
+		history: history,
+		seen:    make(map[uint64]int),
+	}
+}
+
+// Add records the hash of the world after turn completed turns. If the same world was seen
+// within the history it returns the turn it was first seen and true.
+func (d *CycleDetector) Add(turn int, hash uint64) (int, bool) {
+	if firstSeen, ok := d.seen[hash]; ok {
+		return firstSeen, true
+	}
+	d.seen[hash] = turn
+	d.hashes = append(d.hashes, hash)
+	if len(d.hashes) > d.history {
+		delete(d.seen, d.hashes[0])
+		d.hashes = d.hashes[1:]
+	}
+	return 0, false
+}
+
+// CellHash returns the contribution of the cell at (x, y) holding value to the hash of a world.
+// The hash of a world is the XOR of the contributions of all its cells, dead cells contribute 0,
+// so it can be updated as cells flip instead of being computed again each turn.
+func CellHash(x, y int, value uint8) uint64 {
+	if value == 0 {
+		return 0
+	}
+	// splitmix64 finaliser over the packed coordinates and value
+	z := uint64(uint32(x)) | uint64(uint32(y))<<32
+	z ^= uint64(value) * 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// HashWorld returns the hash of every cell of world.
+func HashWorld(world [][]uint8) uint64 {
+	var hash uint64
+	for y := range world {
+		for x, value := range world[y] {
+			hash ^= CellHash(x, y, value)
+		}
+	}
+	return hash
+}
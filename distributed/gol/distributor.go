@@ -16,8 +16,9 @@ type distributorChannels struct {
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
 	ioFilename chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
+	ioOutput   chan<- ioImage
+	ioInput    <-chan [][]uint8
+	ioInputErr <-chan error
 	keyPresses <-chan rune
 }
 
@@ -25,12 +26,33 @@ type distributorChannels struct {
 type Request struct {
 	Parameters   Params
 	InitialWorld [][]uint8
+	Turn         int // turn InitialWorld was reached on, only non-zero when resuming from a checkpoint
+	Session      int // session the run is for, from Controler.StartSession_RPC
+}
+
+// Request to the broker for the run of a session
+type SessionRequest struct {
+	Session int
+}
+
+// Request to the broker to set the speed of the run of a session
+type SpeedRequest struct {
+	Session        int
+	TurnsPerSecond int
+}
+
+// Request to the broker for the nodes lost and rejoined during a session, after the number already seen
+type NodeStatusRequest struct {
+	Session int
+	Seen    int
 }
 
 // Response from the GOLEngine
 type CurrentResponse struct {
 	CurrentWorld [][]uint8
 	CurrentTurns int
+	Checkpoint   bool // fetched by a client to write as a checkpoint rather than an image
+	Frame        bool // fetched by a client to report as the cells flipped since the last frame, for a recording
 }
 
 // Pausing Response from GOLEngine
@@ -44,22 +66,93 @@ type FinalResponse struct {
 	FinalWorld          [][]uint8
 	FinalAliveCellCount []util.Cell
 	CompleteTurns       int
+	Cycle               CycleDetected // Period is 0 if no cycle was detected
+	Detached            bool          // the controller detached, the world is the last one fetched and the run goes on
+}
+
+// Response from the broker to a controller attaching to a detached run, with the world the run has reached
+type AttachResponse struct {
+	World  [][]uint8
+	Turn   int
+	Paused bool
+}
+
+// Status of a worker node that was lost or rejoined during a run
+type NodeStatus struct {
+	CompletedTurns int
+	Address        string
+	Lost           bool
+}
+
+// A session of the broker, listed to find runs left behind by their controller
+type SessionInfo struct {
+	Session     int
+	Running     bool // whether the run has started
+	Attached    bool // whether a controller is attached, a detached run is left for one to attach to
+	Finished    bool // whether the run has finished, waiting for a controller to attach and fetch its final turn
+	Turn        int
+	Turns       int
+	ImageWidth  int
+	ImageHeight int
 }
 
 var pausing bool = false
 var pausingMtx sync.Mutex
 
+// ListSessions returns the sessions of the broker in the order they started
+func ListSessions() ([]SessionInfo, error) {
+	client, err := rpc.Dial("tcp", "127.0.0.1:8030")
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	var sessions []SessionInfo
+	if err := client.Call("Controler.Sessions_RPC", struct{}{}, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// SessionParams returns the parameters of the detached run of a session, to attach to it with Params.Attach. The
+// speed and recording are those the run has now.
+func SessionParams(session int) (Params, error) {
+	client, err := rpc.Dial("tcp", "127.0.0.1:8030")
+	if err != nil {
+		return Params{}, err
+	}
+	defer client.Close()
+	var p Params
+	if err := client.Call("Controler.SessionParams_RPC", SessionRequest{session}, &p); err != nil {
+		return Params{}, err
+	}
+	p.Attach = session
+	return p, nil
+}
+
+// Attach to the detached run of a session, returning the world it has reached
+func attachSession(session int) (AttachResponse, error) {
+	var response AttachResponse
+	client, err := rpc.Dial("tcp", "127.0.0.1:8030")
+	if err != nil {
+		return response, err
+	}
+	defer client.Close()
+	err = client.Call("Controler.AttachBroker_RPC", SessionRequest{session}, &response)
+	return response, err
+}
+
 // Create ticker to control sending alive cell each 2 sec
-func createAliveCellTicker(c distributorChannels, client *rpc.Client, quitTicker chan bool) {
+func createAliveCellTicker(c distributorChannels, client *rpc.Client, session int, quitTicker chan bool, seen *int) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 	var response AliveCellsCount
 	for {
 		select {
 		case <-ticker.C:
+			reportNodeStatus(c, client, session, seen)
 			pausingMtx.Lock()
 			if !pausing {
-				client.Call("Controler.CountAliveCells_RPC", struct{}{}, &response)
+				client.Call("Controler.CountAliveCells_RPC", SessionRequest{session}, &response)
 				c.events <- response
 			}
 			pausingMtx.Unlock()
@@ -69,18 +162,88 @@ func createAliveCellTicker(c distributorChannels, client *rpc.Client, quitTicker
 	}
 }
 
+// Report the worker nodes lost or rejoined after the number already seen
+func reportNodeStatus(c distributorChannels, client *rpc.Client, session int, seen *int) {
+	var statuses []NodeStatus
+	client.Call("Controler.NodeStatus_RPC", NodeStatusRequest{session, *seen}, &statuses)
+	*seen += len(statuses)
+	for _, status := range statuses {
+		if status.Lost {
+			c.events <- NodeLost{status.CompletedTurns, status.Address}
+		} else {
+			c.events <- NodeRejoined{status.CompletedTurns, status.Address}
+		}
+	}
+}
+
+// Fetch the snapshots, checkpoints and recording frames of the run, until the broker reports the run has finished
+func fetchSnapshots(client *rpc.Client, session int, snapshots chan<- CurrentResponse) {
+	for {
+		var snapshot CurrentResponse
+		client.Call("Controler.NextSnapshot_RPC", SessionRequest{session}, &snapshot)
+		if snapshot.CurrentWorld == nil {
+			return
+		}
+		snapshots <- snapshot
+	}
+}
+
+// Output a world the broker sent as a PGM image. The world is the client's own copy, so it is written while the run
+// goes on and the io goroutine sends ImageOutputComplete once it has been
+func saveSnapshot(p Params, c distributorChannels, currentResponse CurrentResponse) {
+	turn := currentResponse.CurrentTurns
+	c.ioCommand <- ioOutput
+	c.ioOutput <- ioImage{OutputName(p, turn), turn, currentResponse.CurrentWorld}
+}
+
+// Report the cells flipped since the last frame of a recording, or since the world was loaded, and return the frame
+func reportFrame(c distributorChannels, last [][]uint8, frame CurrentResponse) [][]uint8 {
+	cellsFlipped := CellsFlipped{CompletedTurns: frame.CurrentTurns}
+	for y := range frame.CurrentWorld {
+		for x, cell := range frame.CurrentWorld[y] {
+			if delta := cell ^ last[y][x]; delta != 0 {
+				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{x, y})
+				cellsFlipped.Deltas = append(cellsFlipped.Deltas, delta)
+			}
+		}
+	}
+	c.events <- cellsFlipped
+	c.events <- TurnComplete{frame.CurrentTurns}
+	return frame.CurrentWorld
+}
+
+// Write a world the broker sent as the checkpoint of the run
+func writeCheckpoint(p Params, currentResponse CurrentResponse) {
+	rule, err := ParseRule(p.Rule)
+	util.Check(err)
+	checkpoint := Checkpoint{
+		Turn:     currentResponse.CurrentTurns,
+		Rule:     rule.String(),
+		Boundary: p.Boundary,
+		World:    currentResponse.CurrentWorld,
+	}
+	util.Check(WriteCheckpoint(CheckpointPath(p), checkpoint))
+}
+
 // Makes a call to run the world update
-func runGameCall(p Params, c distributorChannels, client *rpc.Client, world [][]uint8, finalResponsechan chan FinalResponse, quitDetector chan bool) {
+func runGameCall(p Params, c distributorChannels, client *rpc.Client, session int, world [][]uint8, turn int, finalResponsechan chan FinalResponse, snapshots chan CurrentResponse, quitDetector chan bool) {
 	request := Request{
 		p,
 		world,
+		turn,
+		session,
 	}
 	var finalResponse FinalResponse
 
 	var UpdateWorldBrokerwg sync.WaitGroup
 	UpdateWorldBrokerwg.Add(1)
 	go func() {
-		client.Call("Controler.RunGameBrokerCall_RPC", request, &finalResponse)
+		if p.Attach != 0 {
+			// The broker goes on with the run attached to, the controller waits for it to finish
+			client.Call("Controler.WaitBroker_RPC", SessionRequest{session}, &finalResponse)
+		} else {
+			client.Call("Controler.RunGameBrokerCall_RPC", request, &finalResponse)
+		}
 		UpdateWorldBrokerwg.Done()
 	}()
 
@@ -96,50 +259,59 @@ func runGameCall(p Params, c distributorChannels, client *rpc.Client, world [][]
 	}
 	defer client3.Close()
 
-	go createAliveCellTicker(c, client3, quitTicker)
+	// The nodes lost and rejoined are reported with the alive cells, and once more when the run has finished
+	nodeStatusSeen := 0
+	go createAliveCellTicker(c, client3, session, quitTicker, &nodeStatusSeen)
+
+	// Snapshots are saved by detectKeyPressesCall, which owns the io. Frames of a recording may be sent at any time
+	var snapshotswg sync.WaitGroup
+	snapshotswg.Add(1)
+	go func() {
+		fetchSnapshots(client3, session, snapshots)
+		snapshotswg.Done()
+	}()
 
 	UpdateWorldBrokerwg.Wait()
+	snapshotswg.Wait()
 	quitTicker <- true
 	close(quitTicker)
+	reportNodeStatus(c, client3, session, &nodeStatusSeen)
+
+	// The broker keeps a session detached from for a controller to attach to later
+	if !finalResponse.Detached {
+		client3.Call("Controler.EndSession_RPC", SessionRequest{session}, &struct{}{})
+	}
 	quitDetector <- true
 	finalResponsechan <- finalResponse
 }
 
 // Makes a call to detect the key presses
-func detectKeyPressesCall(p Params, c distributorChannels, client *rpc.Client, quitDetector chan bool) {
+func detectKeyPressesCall(p Params, c distributorChannels, client *rpc.Client, session int, world [][]uint8, snapshots chan CurrentResponse, quitDetector chan bool) {
+	turnsPerSecond := p.TurnsPerSecond
+	lastFrame := world
 	for {
 		select {
 		case key := <-c.keyPresses:
 			if key == 's' {
 				var currentResponse CurrentResponse
 
-				client.Call("Controler.SaveCurrentWorld_RPC", struct{}{}, &currentResponse)
-
-				currentImgFilename := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, currentResponse.CurrentTurns)
-				currentWorld := ImageOutputComplete{
-					currentResponse.CurrentTurns,
-					currentImgFilename,
+				// The session has ended if the run finished as 's' was pressed
+				if err := client.Call("Controler.SaveCurrentWorld_RPC", SessionRequest{session}, &currentResponse); err == nil {
+					saveSnapshot(p, c, currentResponse)
 				}
 
-				c.ioCommand <- ioOutput
-				c.ioFilename <- currentImgFilename
-				for y := 0; y < p.ImageHeight; y++ {
-					for x := 0; x < p.ImageWidth; x++ {
-						c.ioOutput <- currentResponse.CurrentWorld[y][x]
-					}
-				}
+			} else if key == 'q' {
 
-				c.ioCommand <- ioCheckIdle
-				<-c.ioIdle
-				c.events <- currentWorld
+				// Detach, leaving the broker to go on with the run
+				client.Call("Controler.DetachBroker_RPC", SessionRequest{session}, &struct{}{})
 
-			} else if key == 'q' {
+			} else if key == 'x' {
 
-				client.Call("Controler.QuitBroker_RPC", struct{}{}, &struct{}{})
+				client.Call("Controler.QuitBroker_RPC", SessionRequest{session}, &struct{}{})
 
 			} else if key == 'k' {
 
-				client.Call("Controler.CloseBroker_RPC", struct{}{}, &struct{}{})
+				client.Call("Controler.CloseBroker_RPC", SessionRequest{session}, &struct{}{})
 
 			} else if key == 'p' {
 				pausingMtx.Lock()
@@ -147,7 +319,7 @@ func detectKeyPressesCall(p Params, c distributorChannels, client *rpc.Client, q
 				pausingMtx.Unlock()
 
 				var pausingResponse PausingResponse
-				client.Call("Controler.PauseBroker_RPC", struct{}{}, &pausingResponse)
+				client.Call("Controler.PauseBroker_RPC", SessionRequest{session}, &pausingResponse)
 
 				if !pausingResponse.PausingState {
 					fmt.Println(pausingResponse.Turn)
@@ -156,6 +328,36 @@ func detectKeyPressesCall(p Params, c distributorChannels, client *rpc.Client, q
 					fmt.Println(pausingResponse.Turn)
 					c.events <- StateChange{pausingResponse.Turn, Paused}
 				}
+			} else if key == 'n' {
+				// Run a single turn while paused
+				var pausingResponse PausingResponse
+				client.Call("Controler.StepBroker_RPC", SessionRequest{session}, &pausingResponse)
+
+				if pausingResponse.PausingState {
+					c.events <- StateChange{pausingResponse.Turn, Paused}
+				}
+			} else if key == '+' || key == '-' {
+				if key == '+' {
+					turnsPerSecond = FasterSpeed(turnsPerSecond)
+				} else {
+					turnsPerSecond = SlowerSpeed(turnsPerSecond)
+				}
+
+				var speedChanged SpeedChanged
+				client.Call("Controler.SetSpeedBroker_RPC", SpeedRequest{session, turnsPerSecond}, &speedChanged)
+				c.events <- speedChanged
+			} else if key == 'r' {
+				var recordingToggled RecordingToggled
+				client.Call("Controler.RecordBroker_RPC", SessionRequest{session}, &recordingToggled)
+				c.events <- recordingToggled
+			}
+		case snapshot := <-snapshots:
+			if snapshot.Checkpoint {
+				writeCheckpoint(p, snapshot)
+			} else if snapshot.Frame {
+				lastFrame = reportFrame(c, lastFrame, snapshot)
+			} else {
+				saveSnapshot(p, c, snapshot)
 			}
 		case <-quitDetector:
 			return
@@ -163,29 +365,79 @@ func detectKeyPressesCall(p Params, c distributorChannels, client *rpc.Client, q
 	}
 }
 
+// inputFailed reports that the image to start from could not be read, and quits.
+func inputFailed(events chan<- Event, turn int, err error) {
+	events <- ImageInputFailed{turn, err}
+	events <- StateChange{turn, Quitting}
+	close(events)
+}
+
 // Distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
+func distributor(p Params, resume *Checkpoint, c distributorChannels) {
 	// Create 2D slice to initialise world
 	world := make([][]uint8, p.ImageHeight)
 	for i := 0; i < p.ImageHeight; i++ {
 		world[i] = make([]uint8, p.ImageWidth)
 	}
+	startTurn := 0
+	paused := false
+
+	if p.Attach != 0 {
+		// Continue from the world the detached run has reached instead of loading the image
+		attached, err := attachSession(p.Attach)
+		if err != nil {
+			inputFailed(c.events, startTurn, err)
+			return
+		}
+		world, startTurn, paused = attached.World, attached.Turn, attached.Paused
+	} else if resume != nil {
+		// Continue from the checkpoint instead of loading the image
+		world = resume.World
+		startTurn = resume.Turn
+	} else {
+		// Get filename and load initial world state, or the input placed in it
+		if input := p.InputFile(); input != "" {
+			c.ioCommand <- ioInputPattern
+			c.ioFilename <- input
+		} else {
+			filename := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
+			c.ioCommand <- ioInput
+			c.ioFilename <- filename
+		}
+		if err := <-c.ioInputErr; err != nil {
+			inputFailed(c.events, startTurn, err)
+			return
+		}
+		rule, err := ParseRule(p.Rule)
+		util.Check(err)
+
+		// Initialising world
+		world = <-c.ioInput
+		for y := range world {
+			for x, cell := range world[y] {
+				world[y][x] = loadPgmCell(cell, rule)
+			}
+		}
+	}
 
-	// Get filename and load initial world state
-	filename := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
-	c.ioCommand <- ioInput
-	c.ioFilename <- filename
-
-	// Initialising world
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			cell := <-c.ioInput
-			world[y][x] = cell
+	// Report the cells of the loaded world, or of the whole world attached to, which the frames of a recording flip from
+	reportFlipped := CellsFlipped{CompletedTurns: startTurn}
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell != 0 {
+				reportFlipped.Cells = append(reportFlipped.Cells, util.Cell{x, y})
+				reportFlipped.Deltas = append(reportFlipped.Deltas, cell)
+			}
 		}
 	}
+	c.events <- reportFlipped
 
-	// Initialise state of running game
-	c.events <- StateChange{0, Executing}
+	// Initialise state of running game, a run attached to may have been paused
+	if paused {
+		c.events <- StateChange{startTurn, Paused}
+	} else {
+		c.events <- StateChange{startTurn, Executing}
+	}
 
 	// Create a local controller connection
 	client1, err := rpc.Dial("tcp", "127.0.0.1:8030")
@@ -206,40 +458,53 @@ func distributor(p Params, c distributorChannels) {
 	}
 	defer client2.Close()
 
+	// Start a session for the run, which the broker keeps apart from the runs of other controllers
+	session := p.Attach
+	if session == 0 {
+		client2.Call("Controler.StartSession_RPC", struct{}{}, &session)
+
+		// Set the target speed before the run starts, so that '+' and '-' pressed early are not overridden
+		var speedChanged SpeedChanged
+		client2.Call("Controler.SetSpeedBroker_RPC", SpeedRequest{session, p.TurnsPerSecond}, &speedChanged)
+	}
+
+	// The broker starts each run executing, whatever state the last run ended in, and a run attached to as it was left
+	pausingMtx.Lock()
+	pausing = paused
+	pausingMtx.Unlock()
+
 	quitDetector := make(chan bool)
 	finalResponseChan := make(chan FinalResponse)
-	go runGameCall(p, c, client1, world, finalResponseChan, quitDetector)
-	detectKeyPressesCall(p, c, client2, quitDetector)
+	snapshots := make(chan CurrentResponse)
+	go runGameCall(p, c, client1, session, world, startTurn, finalResponseChan, snapshots, quitDetector)
+	detectKeyPressesCall(p, c, client2, session, world, snapshots, quitDetector)
 	response := <-finalResponseChan
-
-	// Report the final state using FinalTurnCompleteEvent.
 	turn := response.CompleteTurns
-	aliveCellsCount := response.FinalAliveCellCount
-	finalTurnComplete := FinalTurnComplete{
-		turn,
-		aliveCellsCount,
-	}
-	c.ioCommand <- ioCheckIdle
-	<-c.ioIdle
-	c.events <- finalTurnComplete
 
-	// Output the state of the board as final PGM image
-	c.ioCommand <- ioOutput
-	finalImgFilename := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn)
-	c.ioFilename <- finalImgFilename
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			c.ioOutput <- response.FinalWorld[y][x]
+	if response.Detached {
+		// The run goes on without the controller, the session is reported to attach to it again with -attach
+		c.events <- SessionDetached{turn, session}
+	} else {
+		// Report a cycle the broker detected before the final state
+		if response.Cycle.Period > 0 {
+			c.events <- response.Cycle
 		}
-	}
 
-	finalWorld := ImageOutputComplete{
-		turn,
-		finalImgFilename,
+		// Report the final state using FinalTurnCompleteEvent.
+		aliveCellsCount := response.FinalAliveCellCount
+		finalTurnComplete := FinalTurnComplete{
+			turn,
+			aliveCellsCount,
+		}
+		c.ioCommand <- ioCheckIdle
+		<-c.ioIdle
+		c.events <- finalTurnComplete
 	}
-	c.ioCommand <- ioCheckIdle
-	<-c.ioIdle
-	c.events <- finalWorld
+
+	// Output the state of the board as final PGM image, or the last world fetched when detaching
+	c.ioCommand <- ioOutput
+	c.ioOutput <- ioImage{OutputName(p, turn), turn, response.FinalWorld}
+
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
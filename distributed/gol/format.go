@@ -0,0 +1,311 @@
+package gol
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Format selects the file format worlds are output in, and tells apart the formats patterns are read from.
+type Format int
+
+const (
+	// PGM is a binary greyscale image with a byte per cell. It is the default output.
+	PGM Format = iota
+	// RLE is the run length encoded pattern format, see rle.go.
+	RLE
+	// Cells is the plaintext format, a line of '.' and 'O' per row after '!' comment lines.
+	Cells
+	// Life106 lists the x and y coordinates of every alive cell, one per line, after a "#Life 1.06" header.
+	Life106
+)
+
+var formatNames = map[Format]string{
+	PGM:     "pgm",
+	RLE:     "rle",
+	Cells:   "cells",
+	Life106: "life",
+}
+
+var formatExtensions = map[Format]string{
+	PGM:     ".pgm",
+	RLE:     ".rle",
+	Cells:   ".cells",
+	Life106: ".lif",
+}
+
+// life106Magic is the first line of a Life 1.06 file.
+const life106Magic = "#Life 1.06"
+
+// ParseFormat parses a format by name: pgm, rle, cells or life. An empty name parses to PGM.
+func ParseFormat(s string) (Format, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return PGM, nil
+	}
+	for format, name := range formatNames {
+		if name == s {
+			return format, nil
+		}
+	}
+	return PGM, fmt.Errorf("format %q: expected one of pgm, rle, cells or life", s)
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "Incorrect Format"
+}
+
+// Extension returns the file extension of the format, including the dot.
+func (f Format) Extension() string {
+	return formatExtensions[f]
+}
+
+// DetectFormat returns the format of a pattern file from the extension of path, or else from the start of
+// its contents: a "#Life 1.06" header, a '!' comment or row of '.' and 'O' for Cells, and RLE otherwise.
+func DetectFormat(path string, data []byte) Format {
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == ".life" {
+		return Life106
+	}
+	for format, formatExtension := range formatExtensions {
+		if extension == formatExtension {
+			return format
+		}
+	}
+	text := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(text, life106Magic):
+		return Life106
+	case strings.HasPrefix(text, "P5") || strings.HasPrefix(text, "P2"):
+		return PGM
+	case strings.HasPrefix(text, "!") || strings.HasPrefix(text, ".") || strings.HasPrefix(text, "O"):
+		return Cells
+	default:
+		return RLE
+	}
+}
+
+// ReadPatternFile reads the pattern of an RLE, Cells, Life 1.06 or PGM file at path, detecting which it is.
+// The grey levels of a PGM image are kept in Image, its errors are *PgmError.
+func ReadPatternFile(path string) (Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Pattern{}, err
+	}
+	var pattern Pattern
+	switch format := DetectFormat(path, data); format {
+	case PGM:
+		if pattern.Image, err = ReadPgm(bytes.NewReader(data)); err != nil {
+			return pattern, &PgmError{path, err}
+		}
+		pattern.Width, pattern.Height = len(pattern.Image[0]), len(pattern.Image)
+	case RLE:
+		pattern, err = ReadRLE(bytes.NewReader(data))
+	case Cells:
+		pattern, err = ReadCells(bytes.NewReader(data))
+	case Life106:
+		pattern, err = ReadLife106(bytes.NewReader(data))
+	}
+	if err != nil {
+		return pattern, fmt.Errorf("%v: %v", path, err)
+	}
+	return pattern, nil
+}
+
+// DefaultOutputName is the output name template of images named after their size and turn, as in 512x512x100.
+const DefaultOutputName = "{w}x{h}x{turn}"
+
+// outputNameFields are the fields of an output name template.
+var outputNameFields = []string{"{w}", "{h}", "{turn}", "{input}"}
+
+// CheckOutputName returns an error if template has a '{' that does not start one of the fields {w} and {h}
+// for the size of the world, {turn} for the turn the image is of, and {input} for the name of the input file.
+func CheckOutputName(template string) error {
+	rest := template
+	for _, field := range outputNameFields {
+		rest = strings.ReplaceAll(rest, field, "")
+	}
+	if strings.Contains(rest, "{") || strings.Contains(rest, "}") {
+		return fmt.Errorf("output name %q: expected only the fields %v", template, strings.Join(outputNameFields, ", "))
+	}
+	return nil
+}
+
+// OutputName returns the name, without its extension, of the image of turn in out/. It is p.OutputName with
+// its fields filled in, see CheckOutputName. The name of the input file is WxH when the image was loaded.
+func OutputName(p Params, turn int) string {
+	template := p.OutputName
+	if template == "" {
+		template = DefaultOutputName
+	}
+	input := fmt.Sprintf("%vx%v", p.ImageWidth, p.ImageHeight)
+	if file := p.InputFile(); file != "" {
+		input = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	return strings.NewReplacer(
+		"{w}", strconv.Itoa(p.ImageWidth),
+		"{h}", strconv.Itoa(p.ImageHeight),
+		"{turn}", strconv.Itoa(turn),
+		"{input}", input,
+	).Replace(template)
+}
+
+// WritePattern writes world in format, which must not be PGM. Only RLE keeps the dying cells of Generations rules.
+func WritePattern(w io.Writer, world [][]uint8, rule Rule, format Format) error {
+	switch format {
+	case RLE:
+		return WriteRLE(w, world, rule)
+	case Cells:
+		return WriteCells(w, world)
+	case Life106:
+		var alive []util.Cell
+		for y := range world {
+			for x, cell := range world[y] {
+				if cell == 255 {
+					alive = append(alive, util.Cell{X: x, Y: y})
+				}
+			}
+		}
+		return WriteLife106(w, alive)
+	default:
+		return fmt.Errorf("%v images are not patterns", format)
+	}
+}
+
+// ReadCells reads a pattern in the plaintext Cells format. Comment lines start with '!', in each row
+// 'O' or '*' is an alive cell and '.' a dead one. Rows may be shorter than the widest, or empty.
+func ReadCells(r io.Reader) (Pattern, error) {
+	var pattern Pattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			pattern.Comments = append(pattern.Comments, strings.TrimPrefix(line, "!"))
+			continue
+		}
+		row := make([]int, len(line))
+		for x, tag := range line {
+			switch tag {
+			case 'O', '*':
+				row[x] = 1
+			case '.':
+			default:
+				return pattern, fmt.Errorf("unexpected %q in row %v", tag, len(pattern.States)+1)
+			}
+		}
+		pattern.States = append(pattern.States, row)
+		if len(row) > pattern.Width {
+			pattern.Width = len(row)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return pattern, err
+	}
+	// Trailing empty rows are left out of the pattern
+	for len(pattern.States) > 0 && len(pattern.States[len(pattern.States)-1]) == 0 {
+		pattern.States = pattern.States[:len(pattern.States)-1]
+	}
+	pattern.Height = len(pattern.States)
+	for y, row := range pattern.States {
+		pattern.States[y] = append(row, make([]int, pattern.Width-len(row))...)
+	}
+	return pattern, nil
+}
+
+// WriteCells writes the alive cells of world in the Cells format, a row per line.
+func WriteCells(w io.Writer, world [][]uint8) error {
+	writer := bufio.NewWriter(w)
+	for _, row := range world {
+		for _, cell := range row {
+			if cell == 255 {
+				writer.WriteByte('O')
+			} else {
+				writer.WriteByte('.')
+			}
+		}
+		writer.WriteByte('\n')
+	}
+	return writer.Flush()
+}
+
+// ReadLife106 reads a pattern in the Life 1.06 format. The pattern is the bounding box of its cells,
+// which may have any coordinates.
+func ReadLife106(r io.Reader) (Pattern, error) {
+	var pattern Pattern
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != life106Magic {
+		return pattern, fmt.Errorf("no %v header", life106Magic)
+	}
+	var cells []util.Cell
+	min, max := util.Cell{}, util.Cell{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			pattern.Comments = append(pattern.Comments, strings.TrimPrefix(line, "#"))
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return pattern, fmt.Errorf("line %q: expected x and y", line)
+		}
+		x, errX := strconv.Atoi(fields[0])
+		y, errY := strconv.Atoi(fields[1])
+		if errX != nil || errY != nil {
+			return pattern, fmt.Errorf("line %q: expected x and y", line)
+		}
+		cell := util.Cell{X: x, Y: y}
+		if len(cells) == 0 {
+			min, max = cell, cell
+		}
+		if cell.X < min.X {
+			min.X = cell.X
+		}
+		if cell.Y < min.Y {
+			min.Y = cell.Y
+		}
+		if cell.X > max.X {
+			max.X = cell.X
+		}
+		if cell.Y > max.Y {
+			max.Y = cell.Y
+		}
+		cells = append(cells, cell)
+	}
+	if err := scanner.Err(); err != nil {
+		return pattern, err
+	}
+	if len(cells) > 0 {
+		pattern.Width, pattern.Height = max.X-min.X+1, max.Y-min.Y+1
+	}
+	pattern.States = make([][]int, pattern.Height)
+	for y := range pattern.States {
+		pattern.States[y] = make([]int, pattern.Width)
+	}
+	for _, cell := range cells {
+		pattern.States[cell.Y-min.Y][cell.X-min.X] = 1
+	}
+	return pattern, nil
+}
+
+// WriteLife106 writes cells in the Life 1.06 format, the same cells FinalTurnComplete reports.
+func WriteLife106(w io.Writer, cells []util.Cell) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, life106Magic)
+	for _, cell := range cells {
+		fmt.Fprintln(writer, cell.X, cell.Y)
+	}
+	return writer.Flush()
+}
@@ -1,29 +1,83 @@
 package gol
 
+import "uk.ac.bris.cs/gameoflife/util"
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
-	Turns       int
-	Threads     int
-	ImageWidth  int
-	ImageHeight int
+	Turns          int
+	Threads        int
+	ImageWidth     int
+	ImageHeight    int
+	Rule           string     // Life-like rule in B/S notation, defaults to B3/S23
+	Boundary       Boundary   // what lies beyond the edges of the world, defaults to Torus
+	CycleHistory   int        // how many generations are remembered to detect cycles, 0 disables detection
+	StopOnCycle    bool       // finish the run as soon as a cycle is detected
+	StopWhen       []string   // conditions that finish the run early, such as "population stable for 1000 turns"
+	Triggers       []string   // conditions that save a snapshot, such as "save every 1000 turns"
+	TurnsPerSecond int        // target speed, changed with '+' and '-', 0 for as fast as possible
+	Resume         string     // checkpoint to continue the run from instead of loading the image, its rule, boundary and size replace these
+	Checkpoints    int        // how many turns apart checkpoints are written to CheckpointPath, 0 disables them
+	Pattern        string     // RLE, Cells or Life 1.06 file centred in the world instead of loading the image, the world is sized from it if the size is 0
+	Input          string     // image or pattern file of any format to load instead of images/WxH.pgm, sized from like Pattern
+	Offset         *util.Cell // cell of the world the top left cell of Input or Pattern is placed at, nil centres it
+	Padding        int        // dead cells around Input or Pattern when the world is sized from it
+	OutputName     string     // template of output file names in out/, see OutputName, defaults to DefaultOutputName
+	OutputFormat   Format     // format every image is output in, defaults to PGM
+	Recording      Recording  // turns recorded as an animated GIF when events pass through Record
+	Attach         int        // session of a detached run to attach to instead of starting one, its parameters replace these
+}
+
+// InputFile returns the file to load instead of images/WxH.pgm, Input or else Pattern, or "" for none.
+func (p Params) InputFile() string {
+	if p.Input != "" {
+		return p.Input
+	}
+	return p.Pattern
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	var resume *Checkpoint
+	if p.Attach != 0 {
+		attach, err := SessionParams(p.Attach)
+		if err != nil {
+			inputFailed(events, 0, err)
+			return
+		}
+		p = attach
+	} else if p.Resume != "" {
+		checkpoint, err := ReadCheckpoint(p.Resume)
+		if err != nil {
+			inputFailed(events, 0, err)
+			return
+		}
+		p = checkpoint.Apply(p)
+		resume = &checkpoint
+	} else if input := p.InputFile(); input != "" {
+		pattern, err := ReadPatternFile(input)
+		if err != nil {
+			inputFailed(events, 0, err)
+			return
+		}
+		p = pattern.Apply(p)
+	}
 
 	//	TODO: Put the missing channels in here.
 	ioFilename := make(chan string)
-	ioOutput := make(chan uint8)
-	ioInput := make(chan uint8)
-	ioCommand := make(chan ioCommand)
+	ioOutput := make(chan ioImage, ioQueueLength)
+	ioInput := make(chan [][]uint8)
+	ioInputErr := make(chan error)
+	ioCommand := make(chan ioCommand, ioQueueLength)
 	ioIdle := make(chan bool)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
 		idle:     ioIdle,
+		events:   events,
 		filename: ioFilename,
 		output:   ioOutput,
 		input:    ioInput,
+		inputErr: ioInputErr,
 	}
 	go startIo(p, ioChannels)
 
@@ -34,7 +88,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		ioFilename: ioFilename,
 		ioOutput:   ioOutput,
 		ioInput:    ioInput,
+		ioInputErr: ioInputErr,
 		keyPresses: keyPresses,
 	}
-	distributor(p, distributorChannels)
+	distributor(p, resume, distributorChannels)
 }
@@ -0,0 +1,160 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PgmThreshold is the grey level, after scaling samples to 0-255, from which a pixel loads as an alive cell
+// of a Life-like rule. Generations rules keep the grey levels of their dying states instead.
+const PgmThreshold = 128
+
+// loadPgmCell returns the cell a grey level of an image loads as under rule.
+func loadPgmCell(cell uint8, rule Rule) uint8 {
+	// Grey levels are only meaningful as dying states of a Generations rule, otherwise they are thresholded
+	if rule.IsGenerations() {
+		return cell
+	}
+	if cell >= PgmThreshold {
+		return 255
+	}
+	return 0
+}
+
+// PgmError is the error of a PGM image that could not be read.
+type PgmError struct {
+	Filename string
+	Err      error
+}
+
+func (e *PgmError) Error() string {
+	return fmt.Sprintf("pgm image %v: %v", e.Filename, e.Err)
+}
+
+func (e *PgmError) Unwrap() error {
+	return e.Err
+}
+
+// ReadPgmFile reads the PGM image at path, see ReadPgm. Its errors are *PgmError.
+func ReadPgmFile(path string) ([][]uint8, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, &PgmError{path, err}
+	}
+	defer file.Close()
+	image, err := ReadPgm(file)
+	if err != nil {
+		return nil, &PgmError{path, err}
+	}
+	return image, nil
+}
+
+// ReadPgm reads a PGM image, either P5 with binary samples of one byte, or two bytes most significant
+// first when maxval is above 255, or P2 with decimal samples. The header may hold '#' comments.
+// Samples are scaled from 0-maxval to 0-255.
+func ReadPgm(r io.Reader) ([][]uint8, error) {
+	reader := pgmReader{bufio.NewReader(r)}
+	magic, err := reader.token()
+	if err != nil || (magic != "P5" && magic != "P2") {
+		return nil, errors.New("not a P2 or P5 pgm image")
+	}
+	width, err := reader.number("width", 1, 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	height, err := reader.number("height", 1, 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	maxval, err := reader.number("maxval", 1, 65535)
+	if err != nil {
+		return nil, err
+	}
+
+	image := make([][]uint8, height)
+	sample := make([]byte, 1)
+	if maxval > 255 {
+		sample = make([]byte, 2)
+	}
+	for y := range image {
+		image[y] = make([]uint8, width)
+		for x := range image[y] {
+			var value int
+			if magic == "P2" {
+				value, err = reader.number("sample", 0, maxval)
+			} else if _, err = io.ReadFull(reader.r, sample); err == nil {
+				value = int(sample[0])
+				if maxval > 255 {
+					value = value<<8 | int(sample[1])
+				}
+				if value > maxval {
+					err = fmt.Errorf("sample %v is above maxval %v", value, maxval)
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("image ends after %v of %vx%v samples", y*width+x, width, height)
+			}
+			if err != nil {
+				return nil, err
+			}
+			image[y][x] = uint8((value*255 + maxval/2) / maxval)
+		}
+	}
+	return image, nil
+}
+
+// pgmReader reads the whitespace separated tokens of a PGM image.
+type pgmReader struct {
+	r *bufio.Reader
+}
+
+// token returns the next token, skipping whitespace and comments from '#' to the end of the line.
+// The whitespace byte that ends the token is consumed, so after maxval the binary samples follow.
+func (p pgmReader) token() (string, error) {
+	var token strings.Builder
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && token.Len() > 0 {
+				return token.String(), nil
+			}
+			return "", err
+		}
+		switch {
+		case c == '#':
+			if _, err = p.r.ReadString('\n'); err != nil && err != io.EOF {
+				return "", err
+			}
+			if token.Len() > 0 {
+				return token.String(), nil
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			if token.Len() > 0 {
+				return token.String(), nil
+			}
+		default:
+			token.WriteByte(c)
+		}
+	}
+}
+
+// number returns the next token as a number from min to max.
+func (p pgmReader) number(name string, min, max int) (int, error) {
+	token, err := p.token()
+	if err == io.EOF {
+		return 0, fmt.Errorf("image ends before its %v", name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(token)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%v %q is not a number from %v to %v", name, token, min, max)
+	}
+	return n, nil
+}
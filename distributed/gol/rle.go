@@ -0,0 +1,285 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// rleLineLength is the longest line WriteRLE writes, as the format recommends.
+const rleLineLength = 70
+
+// Pattern is a pattern read from a run length encoded (RLE) file, the format most patterns are shared in:
+//
+//	#N Glider
+//	#C Comment lines start with '#'.
+//	x = 3, y = 3, rule = B3/S23
+//	bob$2bo$3o!
+//
+// b is a dead cell, o an alive one, $ ends a row and ! the pattern, each may follow a count. Patterns of
+// Generations rules write dead cells as '.' and the alive and dying states as 'A', 'B' and so on.
+type Pattern struct {
+	Width    int
+	Height   int
+	Rule     string    // the rule of the header, empty if it has none
+	Comments []string  // the comment lines, without their '#'
+	States   [][]int   // the state of each cell, 0 is dead, 1 alive and from 2 on dying
+	Image    [][]uint8 // the grey levels of a PGM image read as a pattern, used instead of States
+}
+
+// ReadRLEFile reads the pattern of the RLE file at path.
+func ReadRLEFile(path string) (Pattern, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Pattern{}, err
+	}
+	defer file.Close()
+	pattern, err := ReadRLE(file)
+	if err != nil {
+		return pattern, fmt.Errorf("%v: %v", path, err)
+	}
+	return pattern, nil
+}
+
+// ReadRLE reads a pattern in RLE format.
+func ReadRLE(r io.Reader) (Pattern, error) {
+	var pattern Pattern
+	scanner := bufio.NewScanner(r)
+	header, ended := false, false
+	var body strings.Builder
+	for !ended && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case !header && strings.HasPrefix(line, "#"):
+			pattern.Comments = append(pattern.Comments, strings.TrimPrefix(line, "#"))
+		case !header:
+			if err := pattern.parseHeader(line); err != nil {
+				return pattern, err
+			}
+			header = true
+		default:
+			body.WriteString(line)
+			ended = strings.Contains(line, "!")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return pattern, err
+	}
+	if !header {
+		return pattern, fmt.Errorf("no x = , y = header")
+	}
+	return pattern, pattern.parseBody(body.String())
+}
+
+// parseHeader parses a header line such as "x = 3, y = 3, rule = B3/S23".
+func (pt *Pattern) parseHeader(line string) error {
+	width, height := -1, -1
+	fields := strings.Split(line, ",")
+	// The rule comes last and may hold commas itself, as Larger than Life rules do
+	if i := strings.Index(line, "rule"); i >= 0 {
+		fields = append(strings.Split(strings.TrimSuffix(strings.TrimSpace(line[:i]), ","), ","), line[i:])
+	}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("header %q: expected name = value", line)
+		}
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		var err error
+		switch name {
+		case "x":
+			width, err = strconv.Atoi(value)
+		case "y":
+			height, err = strconv.Atoi(value)
+		case "rule":
+			// Golly appends the bounded grid the pattern was made on, as in B3/S23:T100,100
+			pt.Rule = strings.SplitN(value, ":", 2)[0]
+		}
+		if err != nil {
+			return fmt.Errorf("header %q: %v", line, err)
+		}
+	}
+	if width < 0 || height < 0 {
+		return fmt.Errorf("header %q: expected x = and y = sizes", line)
+	}
+	pt.Width, pt.Height = width, height
+	return nil
+}
+
+// parseBody parses the runs of cells after the header, up to '!'.
+func (pt *Pattern) parseBody(body string) error {
+	pt.States = make([][]int, pt.Height)
+	for y := range pt.States {
+		pt.States[y] = make([]int, pt.Width)
+	}
+	x, y, count := 0, 0, 0
+	for _, tag := range body {
+		state := -1
+		switch {
+		case tag >= '0' && tag <= '9':
+			count = count*10 + int(tag-'0')
+			continue
+		case tag == '!':
+			return nil
+		case tag == '$':
+			y += maxCount(count)
+			x, count = 0, 0
+			continue
+		case tag == 'b' || tag == '.':
+			state = 0
+		case tag == 'o':
+			state = 1
+		case tag >= 'A' && tag <= 'X':
+			state = int(tag-'A') + 1
+		case tag == ' ' || tag == '\t':
+			continue
+		default:
+			return fmt.Errorf("unexpected %q in the pattern", tag)
+		}
+		for run := maxCount(count); run > 0; run-- {
+			if x >= pt.Width || y >= pt.Height {
+				return fmt.Errorf("pattern does not fit in x = %v, y = %v", pt.Width, pt.Height)
+			}
+			pt.States[y][x] = state
+			x++
+		}
+		count = 0
+	}
+	return fmt.Errorf("pattern does not end with '!'")
+}
+
+// maxCount returns the length of a run whose count may be left out, meaning 1.
+func maxCount(count int) int {
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+// Apply returns p set up to run the pattern. If p has no size the world is sized to fit the pattern
+// with p.Padding dead cells around it, and if p has no rule the rule of the header is used.
+func (pt Pattern) Apply(p Params) Params {
+	if p.ImageWidth == 0 || p.ImageHeight == 0 {
+		p.ImageWidth, p.ImageHeight = pt.Width+2*p.Padding, pt.Height+2*p.Padding
+	}
+	if p.Rule == "" {
+		p.Rule = pt.Rule
+	}
+	return p
+}
+
+// World returns a world of width by height cells with the pattern in its centre, holding the
+// values rule stores for each state.
+func (pt Pattern) World(width, height int, rule Rule) ([][]uint8, error) {
+	return pt.Place(width, height, nil, rule)
+}
+
+// Place returns a world of width by height cells with the top left cell of the pattern at offset, or
+// the pattern in its centre if offset is nil. The pattern must fit in the world.
+func (pt Pattern) Place(width, height int, offset *util.Cell, rule Rule) ([][]uint8, error) {
+	offsetX, offsetY := (width-pt.Width)/2, (height-pt.Height)/2
+	if offset != nil {
+		offsetX, offsetY = offset.X, offset.Y
+	}
+	if offsetX < 0 || offsetY < 0 || offsetX+pt.Width > width || offsetY+pt.Height > height {
+		if offset != nil {
+			return nil, fmt.Errorf("pattern of %vx%v at %v,%v does not fit in a world of %vx%v",
+				pt.Width, pt.Height, offset.X, offset.Y, width, height)
+		}
+		return nil, fmt.Errorf("pattern of %vx%v does not fit in a world of %vx%v", pt.Width, pt.Height, width, height)
+	}
+	world := make([][]uint8, height)
+	for y := range world {
+		world[y] = make([]uint8, width)
+	}
+	for y, row := range pt.Image {
+		for x, cell := range row {
+			world[y+offsetY][x+offsetX] = loadPgmCell(cell, rule)
+		}
+	}
+	for y, row := range pt.States {
+		for x, state := range row {
+			switch {
+			case state == 0:
+			case state == 1:
+				world[y+offsetY][x+offsetX] = 255
+			case rule.IsGenerations() && state < rule.States:
+				world[y+offsetY][x+offsetX] = rule.dying(state)
+			default:
+				return nil, fmt.Errorf("pattern has state %v, which rule %v does not", state, rule)
+			}
+		}
+	}
+	return world, nil
+}
+
+// WriteRLE writes world as a pattern in RLE format. Rows are written in full except for their trailing
+// dead cells, so reading it back gives the same world.
+func WriteRLE(w io.Writer, world [][]uint8, rule Rule) error {
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "x = %v, y = %v, rule = %v\n", width, len(world), rule)
+
+	line := 0
+	emit := func(count int, tag byte) {
+		token := string(tag)
+		if count > 1 {
+			token = strconv.Itoa(count) + token
+		}
+		if line+len(token) > rleLineLength {
+			writer.WriteByte('\n')
+			line = 0
+		}
+		writer.WriteString(token)
+		line += len(token)
+	}
+	tag := func(cell uint8) byte {
+		if !rule.IsGenerations() {
+			if cell == 255 {
+				return 'o'
+			}
+			return 'b'
+		}
+		if cell == 0 {
+			return '.'
+		}
+		return byte('A' + rule.state(cell) - 1)
+	}
+
+	rows := 0
+	for _, row := range world {
+		end := len(row)
+		for end > 0 && row[end-1] == 0 {
+			end--
+		}
+		if end == 0 {
+			rows++
+			continue
+		}
+		if rows > 0 {
+			emit(rows, '$')
+			rows = 0
+		}
+		for x := 0; x < end; {
+			run := 1
+			for x+run < end && tag(row[x+run]) == tag(row[x]) {
+				run++
+			}
+			emit(run, tag(row[x]))
+			x += run
+		}
+		rows = 1
+	}
+	emit(1, '!')
+	writer.WriteByte('\n')
+	return writer.Flush()
+}
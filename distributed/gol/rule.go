@@ -0,0 +1,198 @@
+package gol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule describes a Life-like cellular automaton in B/S notation.
+// Bit n of Birth is set if a dead cell with n alive neighbours becomes alive,
+// bit n of Survive is set if an alive cell with n alive neighbours stays alive.
+//
+// States is only used by Generations rules. An alive cell that does not survive
+// passes through States-2 refractory (dying) states before it becomes dead.
+// Dying cells are neither alive nor dead, they do not count as neighbours and
+// are stored in the world as intermediate grey levels. 0 and 2 mean a Life-like rule.
+//
+// Larger than Life rules count the alive cells up to Range cells away, see ltl.go.
+// Birth and survival are then the intervals BirthMin..BirthMax and SurviveMin..SurviveMax.
+type Rule struct {
+	Birth   uint16
+	Survive uint16
+	States  int
+
+	Range      int
+	VonNeumann bool
+	Middle     bool
+	BirthMin   int
+	BirthMax   int
+	SurviveMin int
+	SurviveMax int
+}
+
+// Conway is the standard Game of Life rule, B3/S23. An empty rule string parses to it.
+var Conway = Rule{Birth: 1 << 3, Survive: 1<<2 | 1<<3}
+
+// ParseRule parses a rule such as "B3/S23", "B36/S23" (HighLife) or "B2/S" (Seeds).
+// The B and S parts may come in either order and are case-insensitive.
+// Larger than Life rules start with R, e.g. "R5,C0,M1,S34..58,B34..45,NM" (Bosco's rule).
+//
+// Generations rules are accepted in Golly notation S/B/C, e.g. "/2/3" (Brian's Brain)
+// or "345/2/4" (Star Wars), or as B/S with a third C part, e.g. "B2/S/C3".
+// The digit-only form "23/3" is read as S/B like Golly does.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Conway, nil
+	}
+	if s[0]|0x20 == 'r' {
+		return parseLargerThanLife(s)
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Rule{}, fmt.Errorf("rule %q: expected the form B<digits>/S<digits> or S/B/C", s)
+	}
+	var rule Rule
+	if len(parts) == 3 {
+		states, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(parts[2], "C"), "c"))
+		if err != nil || states < 2 || states > 256 {
+			return Rule{}, fmt.Errorf("rule %q: number of states %q must be between 2 and 256", s, parts[2])
+		}
+		rule.States = states
+	}
+
+	if isDigits(parts[0]) && isDigits(parts[1]) {
+		// Golly S/B notation
+		var err error
+		if rule.Survive, err = neighbourMask(s, parts[0]); err != nil {
+			return Rule{}, err
+		}
+		if rule.Birth, err = neighbourMask(s, parts[1]); err != nil {
+			return Rule{}, err
+		}
+		return rule, nil
+	}
+
+	seen := map[byte]bool{}
+	for _, part := range parts[:2] {
+		if part == "" {
+			return Rule{}, fmt.Errorf("rule %q: empty part", s)
+		}
+		kind := part[0] | 0x20 // lower case
+		if kind != 'b' && kind != 's' {
+			return Rule{}, fmt.Errorf("rule %q: part %q must start with B or S", s, part)
+		}
+		if seen[kind] {
+			return Rule{}, fmt.Errorf("rule %q: %c given twice", s, part[0])
+		}
+		seen[kind] = true
+		mask, err := neighbourMask(s, part[1:])
+		if err != nil {
+			return Rule{}, err
+		}
+		if kind == 'b' {
+			rule.Birth = mask
+		} else {
+			rule.Survive = mask
+		}
+	}
+	return rule, nil
+}
+
+func isDigits(s string) bool {
+	for _, d := range s {
+		if d < '0' || d > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// neighbourMask converts a list of neighbour counts such as "23" into a bit mask.
+func neighbourMask(rule, digits string) (uint16, error) {
+	var mask uint16
+	for _, d := range digits {
+		if d < '0' || d > '8' {
+			return 0, fmt.Errorf("rule %q: neighbour count %q out of range 0-8", rule, d)
+		}
+		mask |= 1 << uint(d-'0')
+	}
+	return mask, nil
+}
+
+// Step returns the next value of a cell given its current value in the world
+// and the number of alive cells among its neighbours.
+func (r Rule) Step(cell uint8, neighbours int) uint8 {
+	switch cell {
+	case 0:
+		if r.born(neighbours) {
+			return 255
+		}
+		return 0
+	case 255:
+		if r.survives(neighbours) {
+			return 255
+		}
+		return r.dying(2)
+	default:
+		return r.dying(r.state(cell) + 1)
+	}
+}
+
+func (r Rule) born(neighbours int) bool {
+	if r.IsLargerThanLife() {
+		return neighbours >= r.BirthMin && neighbours <= r.BirthMax
+	}
+	return r.Birth&(1<<uint(neighbours)) != 0
+}
+
+func (r Rule) survives(neighbours int) bool {
+	if r.IsLargerThanLife() {
+		return neighbours >= r.SurviveMin && neighbours <= r.SurviveMax
+	}
+	return r.Survive&(1<<uint(neighbours)) != 0
+}
+
+// IsGenerations returns whether the rule has refractory states.
+func (r Rule) IsGenerations() bool {
+	return r.States > 2
+}
+
+// dying returns the grey level stored in the world for state k, where state 1 is alive.
+// Dying states are evenly spaced below 255, once k reaches States the cell is dead.
+func (r Rule) dying(k int) uint8 {
+	if k >= r.States {
+		return 0
+	}
+	return uint8(255 - (k-1)*(255/(r.States-1)))
+}
+
+// state is the inverse of dying.
+func (r Rule) state(cell uint8) int {
+	if r.States <= 2 {
+		return r.States
+	}
+	return (255-int(cell))/(255/(r.States-1)) + 1
+}
+
+// String returns the rule in canonical notation, e.g. "B3/S23" or "345/2/4" for Generations.
+func (r Rule) String() string {
+	if r.IsLargerThanLife() {
+		return r.largerThanLifeString()
+	}
+	if r.IsGenerations() {
+		return fmt.Sprintf("%v/%v/%v", neighbourDigits(r.Survive), neighbourDigits(r.Birth), r.States)
+	}
+	return "B" + neighbourDigits(r.Birth) + "/S" + neighbourDigits(r.Survive)
+}
+
+func neighbourDigits(mask uint16) string {
+	var b strings.Builder
+	for n := 0; n <= 8; n++ {
+		if mask&(1<<uint(n)) != 0 {
+			b.WriteByte(byte('0' + n))
+		}
+	}
+	return b.String()
+}
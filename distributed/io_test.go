@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestOutputQueued tests that images saved in quick succession are each written with the world of their own turn,
+// although they are written while the next turns are computed.
+func TestOutputQueued(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, Triggers: []string{"save when turn == 1", "save every 2 turns"}}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	outputs := make(map[string]int)
+	for event := range events {
+		if e, ok := event.(gol.ImageOutputComplete); ok {
+			outputs[e.Filename]++
+		}
+	}
+	// The final image is output as well as the snapshot of the last turn
+	if len(outputs) != 51 || outputs["64x64x100"] != 2 {
+		t.Errorf("%v images output, expected one for turn 1, each even turn and the final one", len(outputs))
+	}
+	for _, turn := range []int{1, 100} {
+		expected := readAliveCells(fmt.Sprintf("check/images/64x64x%v.pgm", turn), 64, 64)
+		alive := readAliveCells(fmt.Sprintf("out/64x64x%v.pgm", turn), 64, 64)
+		assertEqualBoard(t, alive, expected, p)
+	}
+}
@@ -82,7 +82,7 @@ func testKeyboardS(t *testing.T) {
 	golDone := make(chan bool, 1)
 
 	go func() {
-		gol.Run(params, events, keyPresses)
+		gol.Run(params, endWhenDetached(t, events), keyPresses)
 		golDone <- true
 	}()
 
@@ -116,7 +116,7 @@ func testKeyboardQ(t *testing.T) {
 	golDone := make(chan bool, 1)
 
 	go func() {
-		gol.Run(params, events, keyPresses)
+		gol.Run(params, endWhenDetached(t, events), keyPresses)
 		golDone <- true
 	}()
 
@@ -150,7 +150,7 @@ func testKeyboardPS(t *testing.T) {
 	golDone := make(chan bool, 1)
 
 	go func() {
-		gol.Run(params, events, keyPresses)
+		gol.Run(params, endWhenDetached(t, events), keyPresses)
 		golDone <- true
 	}()
 
@@ -189,7 +189,7 @@ func testKeyboardPQ(t *testing.T) {
 	golDone := make(chan bool, 1)
 
 	go func() {
-		gol.Run(params, events, keyPresses)
+		gol.Run(params, endWhenDetached(t, events), keyPresses)
 		golDone <- true
 	}()
 
@@ -6,10 +6,12 @@ import (
 	"runtime"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -41,24 +43,248 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.StringVar(
+		&params.Rule,
+		"rule",
+		"B3/S23",
+		"Specify the rule in B/S (B36/S23), Generations (345/2/4) or Larger than Life (R5,C0,M1,S34..58,B34..45,NM) notation. Defaults to B3/S23.")
+
+	boundary := flag.String(
+		"boundary",
+		"torus",
+		"Specify the boundary of the world: torus, dead, reflect, klein, cylinder-x or cylinder-y. Defaults to torus.")
+
+	flag.IntVar(
+		&params.CycleHistory,
+		"cycles",
+		0,
+		"Specify how many generations to remember to detect cycles of up to that period. Defaults to 0, no detection.")
+
+	flag.BoolVar(
+		&params.StopOnCycle,
+		"stoponcycle",
+		false,
+		"Finish the run as soon as a cycle is detected.")
+
+	stopWhen := flag.String(
+		"stop",
+		"",
+		"Specify conditions that finish the run early, separated by ';', such as \"population == 0; population stable for 1000 turns; time > 10m\".")
+
+	triggers := flag.String(
+		"trigger",
+		"",
+		"Specify triggers that save a snapshot, separated by ';', such as \"save when population > 50k; save every 1000 turns\".")
+
+	flag.IntVar(
+		&params.TurnsPerSecond,
+		"tps",
+		0,
+		"Specify the target number of turns per second, which '+' and '-' change while running. Defaults to 0, as fast as possible.")
+
+	flag.StringVar(
+		&params.Pattern,
+		"pattern",
+		"",
+		"Specify an RLE, Cells or Life 1.06 pattern to centre in the world instead of loading the image. Without -w and -h the world is sized from it, without -rule the rule of an RLE header is used.")
+
+	flag.StringVar(
+		&params.Input,
+		"input",
+		"",
+		"Specify an image or pattern of any format to load instead of images/WxH.pgm. Without -w and -h the world is sized from its header.")
+
+	offset := flag.String(
+		"offset",
+		"",
+		"Specify the x,y cell of the world where the top left cell of -input or -pattern is placed. Defaults to centring it.")
+
+	flag.IntVar(
+		&params.Padding,
+		"pad",
+		0,
+		"Specify how many dead cells surround -input or -pattern when the world is sized from it. Defaults to 0.")
+
+	flag.StringVar(
+		&params.OutputName,
+		"out",
+		gol.DefaultOutputName,
+		"Specify the name of images output in out/, where {w} and {h} are the size of the world, {turn} the turn and {input} the name of the input file. Defaults to "+gol.DefaultOutputName+".")
+
+	format := flag.String(
+		"format",
+		"pgm",
+		"Specify the format of every image output, from 's' or at the end of the run: pgm, rle, cells or life for Life 1.06. Defaults to pgm.")
+
+	flag.StringVar(
+		&params.Resume,
+		"resume",
+		"",
+		"Specify a checkpoint to continue a run from, with its turn, rule, boundary and size.")
+
+	flag.IntVar(
+		&params.Checkpoints,
+		"checkpoint",
+		0,
+		"Specify how many turns apart checkpoints are written to out/WxH.checkpoint, each fetching the whole world from the nodes. Defaults to 0, none are written.")
+
+	flag.BoolVar(
+		&params.Recording.Start,
+		"record",
+		false,
+		"Record the run as an animated GIF from the start, rather than from when 'r' is pressed.")
+
+	flag.IntVar(
+		&params.Recording.Every,
+		"record-every",
+		1,
+		"Specify how many turns apart the frames of a recording are. Defaults to 1, every turn.")
+
+	recordTurns := flag.String(
+		"record-turns",
+		"",
+		"Specify the turns that may be recorded as from-to, or from- without an end. Defaults to every turn.")
+
+	flag.IntVar(
+		&params.Recording.Scale,
+		"record-scale",
+		1,
+		"Specify how many pixels wide and high each cell of a recording is. Defaults to 1.")
+
+	flag.StringVar(
+		&params.Recording.Palette,
+		"palette",
+		"grey",
+		"Specify the colours of a recording: grey, inverse, green, amber or blue. Defaults to grey.")
+
+	flag.IntVar(
+		&params.Attach,
+		"attach",
+		0,
+		"Specify the session of a run detached from with 'q' to attach to, with its parameters, instead of starting a run.")
+
 	headless := flag.Bool(
 		"headless",
 		false,
 		"Disable the SDL window for running in a headless environment.")
 
+	listSessions := flag.Bool(
+		"sessions",
+		false,
+		"List the sessions of the broker with the turn each run has reached, instead of starting a run.")
+
 	flag.Parse()
 
+	if *listSessions {
+		sessions, err := gol.ListSessions()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("%-10v %-20v %-12v %v\n", "Session", "State", "Turn", "Size")
+		for _, session := range sessions {
+			state := "starting"
+			if session.Finished {
+				state = "finished"
+			} else if session.Running {
+				state = "running"
+			}
+			if !session.Attached {
+				state += ", detached"
+			}
+			turn := fmt.Sprintf("%v/%v", session.Turn, session.Turns)
+			fmt.Printf("%-10v %-20v %-12v %vx%v\n", session.Session, state, turn, session.ImageWidth, session.ImageHeight)
+		}
+		return
+	}
+
+	var err error
+	if params.Boundary, err = gol.ParseBoundary(*boundary); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if params.Resume != "" {
+		checkpoint, err := gol.ReadCheckpoint(params.Resume)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		params = checkpoint.Apply(params)
+		fmt.Printf("%-10v %v\n", "Resume", checkpoint.Turn)
+	} else if input := params.InputFile(); input != "" {
+		pattern, err := gol.ReadPatternFile(input)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		// Flags that were not given are taken from the header of the input
+		given := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		if !given["w"] && !given["h"] {
+			params.ImageWidth, params.ImageHeight = 0, 0
+		}
+		if !given["rule"] && pattern.Rule != "" {
+			params.Rule = ""
+		}
+		params = pattern.Apply(params)
+	}
+	if params.Offset, err = parseOffset(*offset); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err = gol.CheckOutputName(params.OutputName); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if params.Recording.From, params.Recording.To, err = gol.ParseTurnRange(*recordTurns); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if _, err = gol.ParsePalette(params.Recording.Palette); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if _, err := gol.ParseRule(params.Rule); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if params.OutputFormat, err = gol.ParseFormat(*format); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if params.Boundary == gol.Unbounded {
+		fmt.Println("boundary unbounded is only supported by the parallel implementation")
+		os.Exit(2)
+	}
+	params.StopWhen = splitList(*stopWhen)
+	params.Triggers = splitList(*triggers)
+	if _, err = gol.NewMonitor(params); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	// A run attached to keeps the parameters it was started with
+	if params.Attach != 0 {
+		if params, err = gol.SessionParams(params.Attach); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		fmt.Printf("%-10v %v\n", "Attach", params.Attach)
+	}
+
 	fmt.Printf("%-10v %v\n", "Threads", params.Threads)
 	fmt.Printf("%-10v %v\n", "Width", params.ImageWidth)
 	fmt.Printf("%-10v %v\n", "Height", params.ImageHeight)
 	fmt.Printf("%-10v %v\n", "Turns", params.Turns)
+	fmt.Printf("%-10v %v\n", "Rule", params.Rule)
+	fmt.Printf("%-10v %v\n", "Boundary", params.Boundary)
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
 
 	go sigterm(keyPresses)
 
-	go gol.Run(params, events, keyPresses)
+	go gol.Run(params, gol.Record(params, events), keyPresses)
 	if !(*headless) {
 		sdl.Run(params, events, keyPresses)
 	} else {
@@ -66,9 +292,33 @@ func main() {
 	}
 }
 
+// sigterm quits the run with 'x' on a signal, rather than detaching with 'q' and leaving it running on the broker.
 func sigterm(keyPresses chan<- rune) {
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGTERM, syscall.SIGINT)
 	<-sigterm
-	keyPresses <- 'q'
+	keyPresses <- 'x'
+}
+
+// splitList splits a list separated by ';', dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseOffset parses an x,y offset, or nil for an empty one.
+func parseOffset(offset string) (*util.Cell, error) {
+	if strings.TrimSpace(offset) == "" {
+		return nil, nil
+	}
+	var cell util.Cell
+	if _, err := fmt.Sscanf(strings.ReplaceAll(offset, " ", ""), "%d,%d", &cell.X, &cell.Y); err != nil {
+		return nil, fmt.Errorf("offset %q: expected x,y", offset)
+	}
+	return &cell, nil
 }
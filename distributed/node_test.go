@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// startNode builds and starts a worker node that registers with the broker, and returns once it has.
+func startNode(t *testing.T, port string) *exec.Cmd {
+	node := filepath.Join(t.TempDir(), "awsNode")
+	if out, err := exec.Command("go", "build", "-o", node, "./awsNode").CombinedOutput(); err != nil {
+		t.Fatalf("building the node failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(node, "-port", port, "-broker", "127.0.0.1:8030")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "Registered") {
+			// Keep reading, so the node does not block writing to a full pipe
+			go io.Copy(io.Discard, stdout)
+			return cmd
+		}
+	}
+	_ = cmd.Process.Kill()
+	t.Fatal("the node did not register with the broker")
+	return nil
+}
+
+// freePort returns a port nothing listens on, so the node started is one the broker has not seen in an earlier run.
+func freePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+// TestNodeLost tests that a run survives a worker node being killed mid-run, reports it and ends with the correct cells.
+func TestNodeLost(t *testing.T) {
+	port := freePort(t)
+	node := startNode(t, port)
+	defer node.Process.Kill()
+
+	// Enough strips that the new node is given some, slowly enough that it is killed mid-run
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 8, TurnsPerSecond: 20}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var lost []gol.NodeLost
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.AliveCellsCount:
+			if node.ProcessState == nil {
+				_ = node.Process.Kill()
+				_ = node.Wait()
+			}
+		case gol.NodeLost:
+			lost = append(lost, e)
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	if len(lost) != 1 || lost[0].Address != "127.0.0.1:"+port {
+		t.Errorf("nodes lost %v, expected 127.0.0.1:%v", lost, port)
+	}
+	assertEqualBoard(t, cells, readAliveCells("check/images/64x64x100.pgm", 64, 64), p)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestPgmRead tests that comments, P2 images, any maxval, 16-bit samples and samples that are whitespace bytes are read.
+func TestPgmRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected [][]uint8
+	}{
+		{"binary", "P5 2 2 255\n\x20\x0a\xff\x09", [][]uint8{{32, 10}, {255, 9}}},
+		{"comments", "P5\n# made by hand\n2 # width\n1\n255\n\x00\xff", [][]uint8{{0, 255}}},
+		{"ascii", "P2\n# a ramp\n3 2\n4\n0 1 2\n3 4\n4\n", [][]uint8{{0, 64, 128}, {191, 255, 255}}},
+		{"maxval", "P5 3 1 1\n\x00\x01\x01", [][]uint8{{0, 255, 255}}},
+		{"16-bit", "P5 2 1 65535\n\x00\x00\xff\xff", [][]uint8{{0, 255}}},
+	}
+	for _, test := range tests {
+		image, err := gol.ReadPgm(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if len(image) != len(test.expected) {
+			t.Errorf("%v: read %v rows, expected %v", test.name, len(image), len(test.expected))
+			continue
+		}
+		for y := range image {
+			if !bytes.Equal(image[y], test.expected[y]) {
+				t.Errorf("%v: row %v read as %v, expected %v", test.name, y, image[y], test.expected[y])
+			}
+		}
+	}
+
+	for _, broken := range []string{"", "P6 1 1 255\n\x00", "P5 0 1 255\n", "P5 1 1 0\n\x00", "P5 2 2 255\n\x00\x00\x00",
+		"P5 1 1 300\n\x02\x00", "P2 1 1 4\n5\n", "P2 2 1 255\n0 x\n", "P5 # no size\n"} {
+		if _, err := gol.ReadPgm(strings.NewReader(broken)); err == nil {
+			t.Errorf("%q accepted", broken)
+		}
+	}
+
+	var pgmError *gol.PgmError
+	if _, err := gol.ReadPgmFile("images/missing.pgm"); !errors.As(err, &pgmError) || pgmError.Filename != "images/missing.pgm" {
+		t.Errorf("missing image read with error %v, expected a *PgmError", err)
+	}
+}
+
+// TestPgmThreshold tests that grey pixels of an image load as alive cells of a Life-like rule from the threshold on.
+func TestPgmThreshold(t *testing.T) {
+	emptyOutFolder()
+	_ = os.WriteFile("out/grey.pgm", []byte("P2\n4 1\n255\n0 127 128 255\n"), 0644)
+	image, err := gol.ReadPgmFile("out/grey.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, expected := range []bool{false, false, true, true} {
+		if alive := image[0][x] >= gol.PgmThreshold; alive != expected {
+			t.Errorf("grey level %v alive is %v, expected %v", image[0][x], alive, expected)
+		}
+	}
+}
+
+// TestPgmInputFailed tests that an image that cannot be read is reported as an event, after which the run quits.
+func TestPgmInputFailed(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 17, ImageHeight: 17, Turns: 10, Threads: 4},
+		{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 4, Pattern: "out/missing.rle"},
+		{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 4, Resume: "out/missing.checkpoint"},
+	}
+	for _, p := range tests {
+		events := make(chan gol.Event)
+		go gol.Run(p, events, nil)
+		var failed *gol.ImageInputFailed
+		quitting := false
+		for event := range events {
+			switch e := event.(type) {
+			case gol.ImageInputFailed:
+				failed = &e
+			case gol.StateChange:
+				quitting = e.NewState == gol.Quitting
+			case gol.FinalTurnComplete:
+				t.Errorf("%+v: run completed despite its input failing", p)
+			}
+		}
+		if failed == nil || failed.Err == nil || !quitting {
+			t.Errorf("%+v: expected Image Input Failed and then Quitting", p)
+			continue
+		}
+		var pgmError *gol.PgmError
+		if isPgm := errors.As(failed.Err, &pgmError); isPgm != (p.Pattern == "" && p.Resume == "") {
+			t.Errorf("%+v: failed with %v", p, failed.Err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"image/gif"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// readRecording decodes the animated GIF a RecordingComplete event reports.
+func readRecording(t *testing.T, recording gol.RecordingComplete) *gif.GIF {
+	file, err := os.Open(fmt.Sprintf("out/%v.gif", recording.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return animation
+}
+
+// TestRecording tests that the selected turns are recorded at scale, with the cells of the world after each of them.
+func TestRecording(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4,
+		Recording: gol.Recording{Start: true, Every: 25, From: 50, Scale: 3, Palette: "inverse"}}
+	events := make(chan gol.Event)
+	go gol.Run(p, gol.Record(p, events), nil)
+	var recordings []gol.RecordingComplete
+	for event := range events {
+		if e, ok := event.(gol.RecordingComplete); ok {
+			recordings = append(recordings, e)
+		}
+	}
+	if len(recordings) != 1 || recordings[0].Frames != 3 || recordings[0].Filename != "16x16x50" {
+		t.Fatalf("recorded %v, expected 3 frames of turns 50, 75 and 100 in 16x16x50", recordings)
+	}
+
+	animation := readRecording(t, recordings[0])
+	if len(animation.Image) != 3 {
+		t.Fatalf("recording has %v frames, expected 3", len(animation.Image))
+	}
+	last := animation.Image[2]
+	if size := last.Bounds().Size(); size.X != 48 || size.Y != 48 {
+		t.Errorf("frames are %vx%v, expected 48x48", size.X, size.Y)
+	}
+	var alive []util.Cell
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			// Inverse draws alive cells black
+			if r, _, _, _ := last.At(x*3+1, y*3+2).RGBA(); r == 0 {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	assertEqualBoard(t, alive, readAliveCells("check/images/16x16x100.pgm", 16, 16), p)
+}
+
+// TestRecordingToggled tests that 'r' starts and stops recording.
+func TestRecordingToggled(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 4, TurnsPerSecond: 200,
+		Recording: gol.Recording{Every: 1}}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	go gol.Run(p, gol.Record(p, events), keyPresses)
+	keyPresses <- 'r'
+	recording, frames := false, 0
+	var recordings []gol.RecordingComplete
+	// Quitting with 'x' does not close the events
+	for quitting := false; !quitting; {
+		switch e := (<-events).(type) {
+		case gol.StateChange:
+			quitting = e.NewState == gol.Quitting
+		case gol.RecordingToggled:
+			recording = e.Recording
+			if !recording {
+				keyPresses <- 'x'
+			}
+		case gol.TurnComplete:
+			if recording {
+				if frames++; frames == 5 {
+					keyPresses <- 'r'
+				}
+			}
+		case gol.RecordingComplete:
+			recordings = append(recordings, e)
+		}
+	}
+	if len(recordings) != 1 || recordings[0].Frames < 5 {
+		t.Fatalf("recorded %v, expected one recording of at least 5 frames", recordings)
+	}
+	if animation := readRecording(t, recordings[0]); len(animation.Image) != recordings[0].Frames {
+		t.Errorf("recording has %v frames, expected %v", len(animation.Image), recordings[0].Frames)
+	}
+}
+
+// TestRecordingOptions tests that turn ranges and palettes are parsed, and which turns are recorded.
+func TestRecordingOptions(t *testing.T) {
+	if from, to, err := gol.ParseTurnRange("100-200"); err != nil || from != 100 || to != 200 {
+		t.Errorf("100-200 parsed as %v-%v, %v", from, to, err)
+	}
+	if from, to, err := gol.ParseTurnRange("10-"); err != nil || from != 10 || to != 0 {
+		t.Errorf("10- parsed as %v-%v, %v", from, to, err)
+	}
+	for _, broken := range []string{"10", "a-b", "20-10", "-5"} {
+		if _, _, err := gol.ParseTurnRange(broken); err == nil {
+			t.Errorf("turns %q accepted", broken)
+		}
+	}
+	if _, err := gol.ParsePalette("rainbow"); err == nil {
+		t.Error("palette rainbow accepted")
+	}
+	if palette, err := gol.ParsePalette("GREEN"); err != nil || len(palette) != 256 {
+		t.Errorf("palette green parsed with %v colours, %v", len(palette), err)
+	}
+
+	recording := gol.Recording{Every: 10, From: 5, To: 45}
+	for turn, expected := range map[int]bool{0: false, 5: true, 10: false, 15: true, 45: true, 55: false} {
+		if recording.Records(turn) != expected {
+			t.Errorf("turn %v recorded is %v, expected %v", turn, !expected, expected)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const gliderRLE = `#N Glider
+#C The smallest spaceship.
+x = 3, y = 3, rule = B3/S23:T16,16
+bob$2bo$3o!
+`
+
+// aliveInWorld returns the alive cells of world.
+func aliveInWorld(world [][]uint8) []util.Cell {
+	var alive []util.Cell
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell == 255 {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return alive
+}
+
+// worldFromCells returns a world of width by height cells where only cells are alive.
+func worldFromCells(cells []util.Cell, width, height int) [][]uint8 {
+	world := make([][]uint8, height)
+	for y := range world {
+		world[y] = make([]uint8, width)
+	}
+	for _, cell := range cells {
+		world[cell.Y][cell.X] = 255
+	}
+	return world
+}
+
+// TestRLERead tests that the header, comments and cells of a pattern are read and that it is centred in the world.
+func TestRLERead(t *testing.T) {
+	pattern, err := gol.ReadRLE(strings.NewReader(gliderRLE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pattern.Width != 3 || pattern.Height != 3 || pattern.Rule != "B3/S23" || len(pattern.Comments) != 2 {
+		t.Errorf("read %vx%v pattern with rule %q and comments %q", pattern.Width, pattern.Height, pattern.Rule, pattern.Comments)
+	}
+	world, err := pattern.World(8, 8, gol.Conway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []util.Cell{{X: 3, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 4}, {X: 3, Y: 4}, {X: 4, Y: 4}}
+	assertEqualBoard(t, aliveInWorld(world), expected, gol.Params{ImageWidth: 8, ImageHeight: 8})
+
+	if _, err = pattern.World(2, 8, gol.Conway); err == nil {
+		t.Error("pattern placed in a world too narrow for it")
+	}
+	for _, broken := range []string{"bob$2bo$3o!", "x = 3, y = 3\nbob$2bo$3o", "x = 3, y = 3\n4o!", "x = 3, y = 3\nbqb!"} {
+		if _, err := gol.ReadRLE(strings.NewReader(broken)); err == nil {
+			t.Errorf("%q accepted", broken)
+		}
+	}
+}
+
+// TestRLERoundTrip tests that worlds written as RLE read back the same, also with the dying states of Generations rules.
+func TestRLERoundTrip(t *testing.T) {
+	brain, _ := gol.ParseRule("/2/3")
+	tests := []struct {
+		rule  gol.Rule
+		world [][]uint8
+	}{
+		{gol.Conway, worldFromCells(readAliveCells("check/images/16x16x0.pgm", 16, 16), 16, 16)},
+		{gol.Conway, [][]uint8{{0, 0, 0}, {0, 0, 0}, {0, 0, 255}, {0, 0, 0}}},
+		{brain, [][]uint8{{255, 128, 0}, {0, 0, 0}, {0, 255, 255}}},
+	}
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		if err := gol.WriteRLE(&buffer, test.world, test.rule); err != nil {
+			t.Fatal(err)
+		}
+		pattern, err := gol.ReadRLE(bytes.NewReader(buffer.Bytes()))
+		if err != nil {
+			t.Fatalf("%v reading back %q", err, buffer.String())
+		}
+		world, err := pattern.World(len(test.world[0]), len(test.world), test.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := range world {
+			if !bytes.Equal(world[y], test.world[y]) {
+				t.Errorf("row %v read back as %v from %q, expected %v", y, world[y], buffer.String(), test.world[y])
+			}
+		}
+	}
+}
+
+// TestRLEPattern tests that a run can start from a pattern, sized from its header or centred in the given world,
+// and that its output is written as RLE as well.
+func TestRLEPattern(t *testing.T) {
+	emptyOutFolder()
+	_ = os.WriteFile("out/glider.rle", []byte(gliderRLE), 0644)
+
+	p := gol.Params{Turns: 0, Threads: 1, Pattern: "out/glider.rle", OutputFormat: gol.RLE}
+	_, final := runAlive(p)
+	expected := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	assertEqualBoard(t, final.Alive, expected, gol.Params{ImageWidth: 3, ImageHeight: 3})
+
+	p = gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4, Pattern: "out/glider.rle", OutputFormat: gol.RLE}
+	_, final = runAlive(p)
+	pattern, err := gol.ReadRLEFile("out/16x16x100.rle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := pattern.World(16, 16, gol.Conway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualBoard(t, aliveInWorld(world), final.Alive, p)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestParseRule tests parsing and validation of Life-like rules in B/S notation.
+func TestParseRule(t *testing.T) {
+	valid := map[string]string{
+		"":                            "B3/S23",
+		"B3/S23":                      "B3/S23",
+		"b36/s23":                     "B36/S23",
+		"S23/B36":                     "B36/S23",
+		"B2/S":                        "B2/S",
+		"B/S012345678":                "B/S012345678",
+		"23/3":                        "B3/S23",
+		"/2/3":                        "/2/3",
+		"B2/S/C3":                     "/2/3",
+		"345/2/4":                     "345/2/4",
+		"R5,C0,M1,S34..58,B34..45,NM": "R5,C0,M1,S34..58,B34..45,NM",
+		"r2,b5,s4..7,nn":              "R2,C0,M0,S4..7,B5..5,NN",
+	}
+	for input, expected := range valid {
+		rule, err := gol.ParseRule(input)
+		if err != nil {
+			t.Errorf("ParseRule(%q) returned error: %v", input, err)
+			continue
+		}
+		if rule.String() != expected {
+			t.Errorf("ParseRule(%q) = %v, expected %v", input, rule, expected)
+		}
+	}
+	for _, input := range []string{"B3", "B3/S23/X", "B9/S23", "X3/S23", "B3/B3", "B3/", "/2/1", "/2/x", "/2/3/4",
+		"R0,S1,B1", "R2,S1", "R2,S1,B1,NX", "R2,S3..1,B1", "R2,S1,B1,X1"} {
+		if _, err := gol.ParseRule(input); err == nil {
+			t.Errorf("ParseRule(%q) should have returned an error", input)
+		}
+	}
+}
+
+// TestRuleConway tests that explicitly passing B3/S23 gives the same boards as the default rule.
+func TestRuleConway(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Threads: 4, Rule: "B3/S23"}
+	for _, turns := range []int{0, 1, 100} {
+		p.Turns = turns
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		t.Run(fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, turns), func(t *testing.T) {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for event := range events {
+				if e, ok := event.(gol.FinalTurnComplete); ok {
+					assertEqualBoard(t, e.Alive, expectedAlive, p)
+				}
+			}
+		})
+	}
+}
+
+// TestRuleGenerations tests that with Brian's Brain every alive cell spends one turn dying.
+func TestRuleGenerations(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 4, Turns: 1, Rule: "/2/3"}
+	initialAlive := readAliveCells("check/images/16x16x0.pgm", p.ImageWidth, p.ImageHeight)
+	emptyOutFolder()
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var alive []util.Cell
+	for event := range events {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			alive = e.Alive
+		}
+	}
+
+	expected := map[util.Cell]bool{}
+	for _, cell := range initialAlive {
+		expected[cell] = true
+	}
+	for _, cell := range alive {
+		if expected[cell] {
+			t.Errorf("cell %v was alive on turn 0 and should be dying on turn 1", cell)
+		}
+		expected[cell] = true
+	}
+	// The output image holds both the alive cells and the dying ones as grey levels
+	notDead := readAliveCells("out/16x16x1.pgm", p.ImageWidth, p.ImageHeight)
+	if len(notDead) != len(expected) {
+		t.Errorf("expected %v non-dead cells in the output image, got %v", len(expected), len(notDead))
+	}
+	for _, cell := range notDead {
+		if !expected[cell] {
+			t.Errorf("cell %v should be dead in the output image", cell)
+		}
+	}
+}
+
+// TestRuleLargerThanLife tests that range 1 Larger than Life rules equivalent to B3/S23 give the same boards.
+func TestRuleLargerThanLife(t *testing.T) {
+	for _, rule := range []string{"R1,C0,M0,S2..3,B3..3,NM", "R1,C0,M1,S3..4,B3..3,NM"} {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Threads: 8, Turns: 100, Rule: rule}
+		expectedAlive := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+		t.Run(rule, func(t *testing.T) {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for event := range events {
+				if e, ok := event.(gol.FinalTurnComplete); ok {
+					assertEqualBoard(t, e.Alive, expectedAlive, p)
+				}
+			}
+		})
+	}
+}
+
+// TestCountNeighbours compares the sliding window neighbour counts against counting every cell.
+func TestCountNeighbours(t *testing.T) {
+	width, height := 64, 64
+	world := make([][]uint8, height)
+	for y := range world {
+		world[y] = make([]uint8, width)
+	}
+	for _, cell := range readAliveCells("check/images/64x64x100.pgm", width, height) {
+		world[cell.Y][cell.X] = 255
+	}
+	for _, name := range []string{"R4,C0,M0,S1,B1,NM", "R4,C0,M1,S1,B1,NN", "R10,C0,M0,S1,B1,NN"} {
+		rule, err := gol.ParseRule(name)
+		util.Check(err)
+		for _, boundary := range []gol.Boundary{gol.Torus, gol.Dead, gol.Reflect, gol.Klein} {
+			counts := rule.CountNeighbours(world, boundary, 0, 20, 5, 64)
+			for y := 0; y < 20; y++ {
+				for x := 5; x < 64; x++ {
+					expected := 0
+					for dy := -rule.Range; dy <= rule.Range; dy++ {
+						for dx := -rule.Range; dx <= rule.Range; dx++ {
+							if rule.VonNeumann && abs(dx)+abs(dy) > rule.Range {
+								continue
+							}
+							if dx == 0 && dy == 0 && !rule.Middle {
+								continue
+							}
+							if boundary.Cell(world, x+dx, y+dy) == 255 {
+								expected++
+							}
+						}
+					}
+					if counts[y][x-5] != expected {
+						t.Fatalf("%v %v: count at (%v, %v) is %v, expected %v", name, boundary, x, y, counts[y][x-5], expected)
+					}
+				}
+			}
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -34,10 +34,20 @@ sdl:
 						keyPresses <- 'p'
 					case sdl.K_s:
 						keyPresses <- 's'
+					case sdl.K_r:
+						keyPresses <- 'r'
 					case sdl.K_q:
 						keyPresses <- 'q'
 					case sdl.K_k:
 						keyPresses <- 'k'
+					case sdl.K_x:
+						keyPresses <- 'x'
+					case sdl.K_n:
+						keyPresses <- 'n'
+					case sdl.K_PLUS, sdl.K_EQUALS, sdl.K_KP_PLUS:
+						keyPresses <- '+'
+					case sdl.K_MINUS, sdl.K_KP_MINUS:
+						keyPresses <- '-'
 					}
 				}
 			}
@@ -54,8 +64,12 @@ sdl:
 			case gol.CellFlipped:
 				w.FlipPixel(e.Cell.X, e.Cell.Y)
 			case gol.CellsFlipped:
-				for _, cell := range e.Cells {
-					w.FlipPixel(cell.X, cell.Y) 
+				for i, cell := range e.Cells {
+					if e.Deltas != nil {
+						w.XorPixel(cell.X, cell.Y, e.Deltas[i])
+					} else {
+						w.FlipPixel(cell.X, cell.Y)
+					}
 				}
 			case gol.TurnComplete:
 				dirty = true
@@ -65,6 +79,18 @@ sdl:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.ImageOutputComplete:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.ImageInputFailed:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.CycleDetected:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.SpeedChanged:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.RecordingToggled:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.RecordingComplete:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.NodeLost, gol.NodeRejoined, gol.SessionDetached:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.StateChange:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 				if e.NewState == gol.Quitting {
@@ -85,6 +111,18 @@ func RunHeadless(events <-chan gol.Event) {
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), "Final Turn Complete")
 		case gol.ImageOutputComplete:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.ImageInputFailed:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.CycleDetected:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.SpeedChanged:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.RecordingToggled:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.RecordingComplete:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.NodeLost, gol.NodeRejoined, gol.SessionDetached:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 		case gol.StateChange:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			if e.NewState == gol.Quitting {
@@ -88,6 +88,20 @@ func (w *Window) FlipPixel(x, y int) {
 	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
 }
 
+// XorPixel applies the XOR of a cell's old and new value to its pixel, so that
+// cells in the dying states of Generations rules show up as grey levels.
+func (w *Window) XorPixel(x, y int, delta uint8) {
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
+		panic(fmt.Sprintf("CellsFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+
+	width := int(w.Width)
+	w.pixels[4*(y*width+x)+0] ^= delta
+	w.pixels[4*(y*width+x)+1] ^= delta
+	w.pixels[4*(y*width+x)+2] ^= delta
+	w.pixels[4*(y*width+x)+3] ^= delta
+}
+
 func (w *Window) CountPixels() int {
 	count := 0
 	for i := 0; i < int(w.Width) * int(w.Height) * 4; i += 4 {
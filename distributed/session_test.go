@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/rpc"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestSessions tests that runs of several controllers share the broker at the same time, and that quitting one of
+// them leaves the others running to their final turn.
+func TestSessions(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, TurnsPerSecond: 50}
+	quitP := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 4}
+
+	finals := make(chan []util.Cell)
+	for i := 0; i < 2; i++ {
+		go func() {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var cells []util.Cell
+			for event := range events {
+				if e, ok := event.(gol.FinalTurnComplete); ok {
+					cells = e.Alive
+				}
+			}
+			finals <- cells
+		}()
+	}
+
+	// Quitting with 'x' ends the run before its last turn, its events are read up to the Quitting state change
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 1)
+	go gol.Run(quitP, events, keyPresses)
+	for quitting := false; !quitting; {
+		switch e := (<-events).(type) {
+		case gol.AliveCellsCount:
+			keyPresses <- 'x'
+		case gol.StateChange:
+			quitting = e.NewState == gol.Quitting
+		case gol.FinalTurnComplete:
+			if e.CompletedTurns >= quitP.Turns {
+				t.Errorf("quit run completed %v turns", e.CompletedTurns)
+			}
+		}
+	}
+
+	expected := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	for i := 0; i < 2; i++ {
+		assertEqualBoard(t, <-finals, expected, p)
+	}
+}
+
+// TestSessionDropped tests that the run of a controller whose connection drops is listed detached and goes on, so
+// that a controller can attach to it again.
+func TestSessionDropped(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 4}
+	world := make([][]uint8, p.ImageHeight)
+	for i := range world {
+		world[i] = make([]uint8, p.ImageWidth)
+	}
+
+	client, err := rpc.Dial("tcp", "127.0.0.1:8030")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session int
+	if err := client.Call("Controler.StartSession_RPC", struct{}{}, &session); err != nil {
+		t.Fatal(err)
+	}
+	client.Go("Controler.RunGameBrokerCall_RPC", gol.Request{p, world, 0, session}, &gol.FinalResponse{}, nil)
+
+	// Wait for the session to be listed in the state expected
+	waitListed := func(state string, expected func(gol.SessionInfo) bool) gol.SessionInfo {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			sessions, err := gol.ListSessions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, info := range sessions {
+				if info.Session == session && expected(info) {
+					return info
+				}
+			}
+		}
+		t.Fatalf("session %v was not listed %v", session, state)
+		return gol.SessionInfo{}
+	}
+	info := waitListed("running", func(info gol.SessionInfo) bool { return info.Running && info.Attached && info.Turn > 0 })
+	if info.Turns != p.Turns || info.ImageWidth != p.ImageWidth || info.ImageHeight != p.ImageHeight {
+		t.Errorf("session listed as %+v, expected the parameters of the run", info)
+	}
+
+	client.Close()
+	detached := waitListed("detached", func(info gol.SessionInfo) bool { return !info.Attached })
+	if final := endSession(session); final.CompletedTurns <= detached.Turn {
+		t.Errorf("run attached to after its connection dropped ended on turn %v, expected after %v", final.CompletedTurns, detached.Turn)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// runAlive runs p to completion and returns the turn of the first event and the final alive cells.
+func runAlive(p gol.Params) (int, gol.FinalTurnComplete) {
+	firstTurn := -1
+	var final gol.FinalTurnComplete
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for event := range events {
+		if firstTurn < 0 {
+			firstTurn = event.GetCompletedTurns()
+		}
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e
+		}
+	}
+	return firstTurn, final
+}
+
+// TestCheckpointResume tests that a run writes checkpoints as it goes, and that a run resumed from one
+// continues from its turn and ends with the same cells as a run that was never interrupted.
+func TestCheckpointResume(t *testing.T) {
+	for _, engine := range []gol.Engine{gol.Bytes, gol.BitPacked} {
+		t.Run(engine.String(), func(t *testing.T) {
+			emptyOutFolder()
+			p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 25, Threads: 4, Engine: engine, Checkpoints: 10}
+			runAlive(p)
+
+			path := gol.CheckpointPath(p)
+			checkpoint, err := gol.ReadCheckpoint(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checkpoint.Turn != 20 || checkpoint.Rule != "B3/S23" || checkpoint.Boundary != gol.Torus {
+				t.Errorf("checkpoint after turn %v with rule %v and boundary %v, expected 20, B3/S23 and torus",
+					checkpoint.Turn, checkpoint.Rule, checkpoint.Boundary)
+			}
+
+			resumed := gol.Params{Turns: 100, Threads: 4, Engine: engine, Resume: path}
+			firstTurn, final := runAlive(resumed)
+			if firstTurn != 20 {
+				t.Errorf("resumed run started after turn %v, expected 20", firstTurn)
+			}
+			if final.CompletedTurns != 100 {
+				t.Errorf("resumed run finished after turn %v, expected 100", final.CompletedTurns)
+			}
+			assertEqualBoard(t, final.Alive, readAliveCells("check/images/16x16x100.pgm", 16, 16), p)
+		})
+	}
+}
+
+// TestCheckpointUnbounded tests that an unbounded world is resumed at the position it had moved to.
+func TestCheckpointUnbounded(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4, Boundary: gol.Unbounded}
+	_, expected := runAlive(p)
+
+	p.Turns, p.Checkpoints = 50, 50
+	runAlive(p)
+	p.Turns, p.Resume = 100, gol.CheckpointPath(p)
+	_, final := runAlive(p)
+	assertEqualBoard(t, final.Alive, expected.Alive, p)
+}
+
+// TestCheckpointErrors tests that files that are not complete checkpoints are rejected.
+func TestCheckpointErrors(t *testing.T) {
+	emptyOutFolder()
+	world := [][]uint8{{0, 255}, {255, 0}}
+	path := "out/test.checkpoint"
+	if err := gol.WriteCheckpoint(path, gol.Checkpoint{Turn: 7, Rule: "B36/S23", Boundary: gol.Klein, Origin: util.Cell{X: -3, Y: 4}, World: world}); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := gol.ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Turn != 7 || checkpoint.Rule != "B36/S23" || checkpoint.Boundary != gol.Klein ||
+		checkpoint.Origin != (util.Cell{X: -3, Y: 4}) || checkpoint.World[0][1] != 255 || checkpoint.World[1][1] != 0 {
+		t.Errorf("read back %+v", checkpoint)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, broken := range []string{"P5\n2 2\n255\n", string(data[:len(data)-1]), "GOL checkpoint 1\nturn x\n"} {
+		_ = os.WriteFile(path, []byte(broken), 0644)
+		if _, err := gol.ReadCheckpoint(path); err == nil {
+			t.Errorf("%q accepted", broken)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStopWhen tests that the run finishes as soon as one of its conditions holds. The glider in the 16x16
+// image keeps 5 cells, first counted after turn 1, so its population is stable for 20 turns after turn 21.
+func TestStopWhen(t *testing.T) {
+	tests := []struct {
+		stopWhen []string
+		turns    int
+	}{
+		{[]string{"population stable for 20 turns"}, 21},
+		{[]string{"turn >= 30", "population == 0"}, 30},
+		{[]string{"population > 5", "time > 1h"}, 100},
+	}
+	for _, test := range tests {
+		for _, engine := range []gol.Engine{gol.Bytes, gol.BitPacked} {
+			p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4, Engine: engine, StopWhen: test.stopWhen}
+			t.Run(fmt.Sprintf("%v/%q", engine, test.stopWhen), func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for event := range events {
+					if e, ok := event.(gol.FinalTurnComplete); ok && e.CompletedTurns != test.turns {
+						t.Errorf("run stopped after %v turns, expected %v", e.CompletedTurns, test.turns)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestTriggers tests that snapshots are saved each time the condition of a trigger starts to hold.
+func TestTriggers(t *testing.T) {
+	for _, engine := range []gol.Engine{gol.Bytes, gol.BitPacked} {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 30, Threads: 4, Engine: engine,
+			Triggers: []string{"save every 10 turns", "save when population > 4"}}
+		t.Run(engine.String(), func(t *testing.T) {
+			emptyOutFolder()
+			var saved []int
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for event := range events {
+				if e, ok := event.(gol.ImageOutputComplete); ok {
+					saved = append(saved, e.CompletedTurns)
+				}
+			}
+			// The final image is output as well
+			expected := []int{1, 10, 20, 30, 30}
+			if fmt.Sprint(saved) != fmt.Sprint(expected) {
+				t.Errorf("images output after turns %v, expected %v", saved, expected)
+			}
+			if _, err := os.Stat("out/16x16x10.pgm"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestConditionErrors tests that malformed conditions and triggers are rejected.
+func TestConditionErrors(t *testing.T) {
+	tests := []gol.Params{
+		{StopWhen: []string{"population"}},
+		{StopWhen: []string{"population = 0"}},
+		{StopWhen: []string{"population > lots"}},
+		{StopWhen: []string{"time > 10"}},
+		{StopWhen: []string{"every 0 turns"}},
+		{StopWhen: []string{"population stable for 10"}},
+		{Triggers: []string{"population > 10"}},
+		{Triggers: []string{"save when population"}},
+	}
+	for _, p := range tests {
+		if _, err := gol.NewMonitor(p); err == nil {
+			t.Errorf("%q %q accepted", p.StopWhen, p.Triggers)
+		}
+	}
+	p := gol.Params{
+		StopWhen: []string{"Population == 0", "population stable for 1k turns", "time > 10m", "every 5 turns"},
+		Triggers: []string{"save when population > 50k", "save every 1000 turns"},
+	}
+	if _, err := gol.NewMonitor(p); err != nil {
+		t.Error(err)
+	}
+}
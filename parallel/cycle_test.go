@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCycleDetected tests that the glider in the 16x16 image is found to return to its starting position after
+// 64 turns, with every engine that supports cycle detection, and that the run can stop there.
+func TestCycleDetected(t *testing.T) {
+	for _, engine := range []gol.Engine{gol.Bytes, gol.BitPacked} {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1000, Threads: 4, Engine: engine, CycleHistory: 100, StopOnCycle: true}
+		expectedAlive := readAliveCells("check/images/16x16x0.pgm", p.ImageWidth, p.ImageHeight)
+		t.Run(engine.String(), func(t *testing.T) {
+			var cycles []gol.CycleDetected
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CycleDetected:
+					cycles = append(cycles, e)
+				case gol.FinalTurnComplete:
+					if e.CompletedTurns != 64 {
+						t.Errorf("run stopped after %v turns, expected 64", e.CompletedTurns)
+					}
+					assertEqualBoard(t, e.Alive, expectedAlive, p)
+				}
+			}
+			expected := []gol.CycleDetected{{Turn: 64, Period: 64, FirstSeen: 0}}
+			if fmt.Sprint(cycles) != fmt.Sprint(expected) {
+				t.Errorf("CycleDetected events %v, expected %v", cycles, expected)
+			}
+		})
+	}
+}
+
+// TestCycleHistory tests that cycles longer than the history are not detected and that the run then continues.
+func TestCycleHistory(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4, CycleHistory: 63, StopOnCycle: true}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CycleDetected:
+			t.Errorf("unexpected %#v", e)
+		case gol.FinalTurnComplete:
+			if e.CompletedTurns != p.Turns {
+				t.Errorf("run stopped after %v turns, expected %v", e.CompletedTurns, p.Turns)
+			}
+		}
+	}
+}
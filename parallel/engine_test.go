@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestBitPacked tests the bit-packed engine against the same 16x16, 64x64 and 512x512 images as TestGol and TestPgm.
+func TestBitPacked(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		p.Engine = gol.BitPacked
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			emptyOutFolder()
+			for _, threads := range []int{1, 3, 8, 16} {
+				p.Threads = threads
+				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				t.Run(testName, func(t *testing.T) {
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					for event := range events {
+						if e, ok := event.(gol.FinalTurnComplete); ok {
+							assertEqualBoard(t, e.Alive, expectedAlive, p)
+						}
+					}
+					cellsFromImage := readAliveCells(
+						"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+						p.ImageWidth,
+						p.ImageHeight,
+					)
+					assertEqualBoard(t, cellsFromImage, expectedAlive, p)
+				})
+			}
+		}
+	}
+}
+
+// TestBitPackedBoundary tests the bit-packed engine with every boundary mode.
+func TestBitPackedBoundary(t *testing.T) {
+	for _, size := range []int{16, 64} {
+		for _, boundary := range []gol.Boundary{gol.Dead, gol.Reflect, gol.Klein, gol.CylinderX, gol.CylinderY} {
+			p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: 50, Threads: 8, Boundary: boundary, Engine: gol.BitPacked}
+			expectedAlive := simulate(readAliveCells(fmt.Sprintf("check/images/%vx%vx0.pgm", size, size), size, size), p)
+			t.Run(fmt.Sprintf("%dx%d-%v", size, size, boundary), func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for event := range events {
+					if e, ok := event.(gol.FinalTurnComplete); ok {
+						assertEqualBoard(t, e.Alive, expectedAlive, p)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkBitPacked(b *testing.B) {
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 1000, Engine: gol.BitPacked}
+	for _, threads := range []int{1, 4, 16} {
+		p.Threads = threads
+		b.Run(fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for range events {
+				}
+			}
+		})
+	}
+}
+
+// TestHashLife tests the HashLife engine against the same images as TestGol, and that it reaches a distant turn.
+func TestHashLife(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		p.Engine = gol.HashLife
+		p.Threads = 1
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			for _, hashStep := range []int{0, 3} {
+				p.HashStep = hashStep
+				t.Run(fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.HashStep), func(t *testing.T) {
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					for event := range events {
+						if e, ok := event.(gol.FinalTurnComplete); ok {
+							assertEqualBoard(t, e.Alive, expectedAlive, p)
+						}
+					}
+				})
+			}
+		}
+	}
+
+	t.Run("64x64x1000000000", func(t *testing.T) {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 1000000000, Threads: 1, Engine: gol.HashLife}
+		events := make(chan gol.Event)
+		go gol.Run(p, events, nil)
+		for event := range events {
+			if e, ok := event.(gol.FinalTurnComplete); ok && e.CompletedTurns != p.Turns {
+				t.Errorf("expected %v completed turns, got %v", p.Turns, e.CompletedTurns)
+			}
+		}
+	})
+}
+
+// TestActiveTiles runs long enough for the 64x64 image to settle into mostly still life, so most tiles are skipped,
+// and checks both the final board and the board rebuilt from CellsFlipped events against a straightforward simulation.
+func TestActiveTiles(t *testing.T) {
+	for _, boundary := range []gol.Boundary{gol.Torus, gol.Dead, gol.Reflect, gol.Klein, gol.CylinderX, gol.CylinderY} {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 1000, Threads: 3, Boundary: boundary}
+		expectedAlive := simulate(readAliveCells("check/images/64x64x0.pgm", 64, 64), p)
+		t.Run(boundary.String(), func(t *testing.T) {
+			flipped := make(map[util.Cell]bool)
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellsFlipped:
+					for _, cell := range e.Cells {
+						flipped[cell] = !flipped[cell]
+					}
+				case gol.FinalTurnComplete:
+					assertEqualBoard(t, e.Alive, expectedAlive, p)
+				}
+			}
+			var replayed []util.Cell
+			for cell, alive := range flipped {
+				if alive {
+					replayed = append(replayed, cell)
+				}
+			}
+			assertEqualBoard(t, replayed, expectedAlive, p)
+		})
+	}
+}
+
+// TestThinStrips tests that a Larger than Life rule gives the same board when the strips of the workers
+// are thinner than its range, so halo rows come from several workers, as when one worker owns the whole world.
+func TestThinStrips(t *testing.T) {
+	for _, boundary := range []gol.Boundary{gol.Torus, gol.Dead, gol.Reflect, gol.Klein, gol.CylinderX, gol.CylinderY} {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 30, Boundary: boundary, Rule: "R5,C0,M1,S34..58,B34..45,NM"}
+		t.Run(boundary.String(), func(t *testing.T) {
+			var boards [][]util.Cell
+			for _, threads := range []int{1, 16} {
+				p.Threads = threads
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for event := range events {
+					if e, ok := event.(gol.FinalTurnComplete); ok {
+						boards = append(boards, e.Alive)
+					}
+				}
+			}
+			assertEqualBoard(t, boards[1], boards[0], p)
+		})
+	}
+}
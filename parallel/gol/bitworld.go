@@ -0,0 +1,258 @@
+package gol
+
+import (
+	"math/bits"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// bitWorld is the world of the BitPacked engine. Bit i of word k in a row holds the cell
+// at x = 64k+i, bits past the width of the world are always 0.
+type bitWorld struct {
+	width, height int
+	words         int // words per row
+	lastMask      uint64
+	rule          Rule
+	boundary      Boundary
+	cells, next   [][]uint64
+	above, below  []uint64 // halo rows at y = -1 and y = height, filled in each turn
+}
+
+func newBitWorld(world [][]uint8, rule Rule, boundary Boundary) *bitWorld {
+	height := len(world)
+	width := len(world[0])
+	words := (width + 63) / 64
+	b := &bitWorld{
+		width:    width,
+		height:   height,
+		words:    words,
+		lastMask: ^uint64(0) >> uint(64*words-width),
+		rule:     rule,
+		boundary: boundary,
+		cells:    make([][]uint64, height),
+		next:     make([][]uint64, height),
+		above:    make([]uint64, words),
+		below:    make([]uint64, words),
+	}
+	for y := 0; y < height; y++ {
+		b.cells[y] = make([]uint64, words)
+		b.next[y] = make([]uint64, words)
+		for x := 0; x < width; x++ {
+			if world[y][x] == 255 {
+				b.cells[y][x/64] |= 1 << uint(x%64)
+			}
+		}
+	}
+	return b
+}
+
+// unpack writes the cells back into a byte per cell world.
+func (b *bitWorld) unpack(world [][]uint8) {
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			if b.cells[y][x/64]&(1<<uint(x%64)) != 0 {
+				world[y][x] = 255
+			} else {
+				world[y][x] = 0
+			}
+		}
+	}
+}
+
+func (b *bitWorld) aliveCount() int {
+	count := 0
+	for _, row := range b.cells {
+		for _, word := range row {
+			count += bits.OnesCount64(word)
+		}
+	}
+	return count
+}
+
+// hash returns the same hash as HashWorld would for the unpacked world.
+func (b *bitWorld) hash() uint64 {
+	var hash uint64
+	for y, row := range b.cells {
+		for k, word := range row {
+			for word != 0 {
+				i := bits.TrailingZeros64(word)
+				hash ^= CellHash(64*k+i, y, 255)
+				word &= word - 1
+			}
+		}
+	}
+	return hash
+}
+
+// step computes the next turn with the rows split between threads workers
+// and returns the cells each of them flipped.
+func (b *bitWorld) step(turn int, threads int) []CellsFlipped {
+	b.fillHalo()
+	flipped := make([]CellsFlipped, threads)
+	var wg sync.WaitGroup
+	unitY := b.height / threads
+	wg.Add(threads)
+	for i := 0; i < threads; i++ {
+		startY := unitY * i
+		endY := unitY * (i + 1)
+		if i == threads-1 {
+			endY = b.height
+		}
+		go func(i, startY, endY int) {
+			defer wg.Done()
+			flipped[i] = b.stepRows(turn, startY, endY)
+		}(i, startY, endY)
+	}
+	wg.Wait()
+	b.cells, b.next = b.next, b.cells
+	return flipped
+}
+
+// fillHalo copies the rows beyond the top and bottom edges according to the boundary.
+func (b *bitWorld) fillHalo() {
+	switch b.boundary {
+	case Torus, CylinderY:
+		copy(b.above, b.cells[b.height-1])
+		copy(b.below, b.cells[0])
+	case Reflect:
+		copy(b.above, b.cells[0])
+		copy(b.below, b.cells[b.height-1])
+	case Klein:
+		b.reverse(b.above, b.cells[b.height-1])
+		b.reverse(b.below, b.cells[0])
+	default:
+		for k := range b.above {
+			b.above[k] = 0
+			b.below[k] = 0
+		}
+	}
+}
+
+// reverse stores row mirrored in x into dst.
+func (b *bitWorld) reverse(dst, row []uint64) {
+	for k := range dst {
+		dst[k] = 0
+	}
+	for x := 0; x < b.width; x++ {
+		if row[x/64]&(1<<uint(x%64)) != 0 {
+			mirrored := b.width - 1 - x
+			dst[mirrored/64] |= 1 << uint(mirrored%64)
+		}
+	}
+}
+
+// edges returns the cells beyond the left and right edges of a row according to the boundary.
+func (b *bitWorld) edges(row []uint64) (west, east uint64) {
+	first := row[0] & 1
+	last := row[(b.width-1)/64] >> uint((b.width-1)%64) & 1
+	switch b.boundary {
+	case Torus, Klein, CylinderX:
+		return last, first
+	case Reflect:
+		return first, last
+	default:
+		return 0, 0
+	}
+}
+
+// shifted returns the words of a row holding, for each cell, its west and east neighbours.
+func (b *bitWorld) shifted(row []uint64, k int) (west, east uint64) {
+	haloWest, haloEast := b.edges(row)
+	west = row[k] << 1
+	if k > 0 {
+		west |= row[k-1] >> 63
+	} else {
+		west |= haloWest
+	}
+	east = row[k] >> 1
+	if k < b.words-1 {
+		east |= row[k+1] << 63
+	} else {
+		east |= haloEast << uint((b.width-1)%64)
+	}
+	return west, east
+}
+
+func (b *bitWorld) stepRows(turn, startY, endY int) CellsFlipped {
+	cellsFlipped := CellsFlipped{
+		CompletedTurns: turn,
+	}
+	// Neighbour counts that let a cell be born or survive
+	var birth, survive []int
+	for n := 0; n <= 8; n++ {
+		if b.rule.Birth&(1<<uint(n)) != 0 {
+			birth = append(birth, n)
+		}
+		if b.rule.Survive&(1<<uint(n)) != 0 {
+			survive = append(survive, n)
+		}
+	}
+
+	for y := startY; y < endY; y++ {
+		up, down := b.above, b.below
+		if y > 0 {
+			up = b.cells[y-1]
+		}
+		if y < b.height-1 {
+			down = b.cells[y+1]
+		}
+		row := b.cells[y]
+		for k := 0; k < b.words; k++ {
+			nw, ne := b.shifted(up, k)
+			w, e := b.shifted(row, k)
+			sw, se := b.shifted(down, k)
+			b0, b1, b2, b3 := countBits(nw, up[k], ne, w, e, sw, down[k], se)
+
+			alive := row[k]
+			var next uint64
+			for _, n := range birth {
+				next |= ^alive & equals(n, b0, b1, b2, b3)
+			}
+			for _, n := range survive {
+				next |= alive & equals(n, b0, b1, b2, b3)
+			}
+			if k == b.words-1 {
+				next &= b.lastMask
+			}
+			b.next[y][k] = next
+
+			for flipped := next ^ alive; flipped != 0; flipped &= flipped - 1 {
+				x := 64*k + bits.TrailingZeros64(flipped)
+				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cellsFlipped
+}
+
+// countBits adds up eight words bit by bit with full adders, returning the four bits
+// of every count from least significant to most significant.
+func countBits(n0, n1, n2, n3, n4, n5, n6, n7 uint64) (b0, b1, b2, b3 uint64) {
+	ones1, twos1 := fullAdder(n0, n1, n2)
+	ones2, twos2 := fullAdder(n3, n4, n5)
+	ones3, twos3 := n6^n7, n6&n7
+	b0, twos4 := fullAdder(ones1, ones2, ones3)
+	twos, fours1 := fullAdder(twos1, twos2, twos3)
+	b1, fours2 := twos^twos4, twos&twos4
+	b2, b3 = fours1^fours2, fours1&fours2
+	return b0, b1, b2, b3
+}
+
+func fullAdder(a, b, c uint64) (sum, carry uint64) {
+	s := a ^ b
+	return s ^ c, a&b | s&c
+}
+
+// equals returns the bits where the count held in b0..b3 is n.
+func equals(n int, b0, b1, b2, b3 uint64) uint64 {
+	result := ^uint64(0)
+	for i, bit := range []uint64{b0, b1, b2, b3} {
+		if n&(1<<uint(i)) != 0 {
+			result &= bit
+		} else {
+			result &= ^bit
+		}
+	}
+	return result
+}
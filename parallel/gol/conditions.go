@@ -0,0 +1,198 @@
+package gol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Condition is a condition on the state of a run, checked after each turn. It is written as one of
+//
+//	population <op> <count>        e.g. "population == 0" or "population > 50k"
+//	population stable for <n> turns
+//	turn <op> <n>
+//	time <op> <duration>           wall time since the run started, e.g. "time > 10m"
+//	every <n> turns
+//
+// where <op> is one of ==, !=, <, <=, > or >=, and counts may end in k or m for thousands or millions.
+type Condition struct {
+	subject  string // population, stable, turn, time or every
+	op       string
+	value    int
+	duration time.Duration
+
+	// The population and the turn it last changed on, for stable
+	population int
+	since      int
+}
+
+// ParseCondition parses a condition such as "population stable for 1000 turns".
+func ParseCondition(s string) (*Condition, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	c := &Condition{population: -1}
+	var err error
+	switch {
+	case len(fields) == 5 && fields[0] == "population" && fields[1] == "stable" && fields[2] == "for" && isTurns(fields[4]):
+		c.subject = "stable"
+		c.value, err = parseCount(fields[3])
+	case len(fields) == 3 && fields[0] == "every" && isTurns(fields[2]):
+		c.subject = "every"
+		c.value, err = parseCount(fields[1])
+		if err == nil && c.value <= 0 {
+			err = fmt.Errorf("%q is not a positive number of turns", fields[1])
+		}
+	case len(fields) == 3 && (fields[0] == "population" || fields[0] == "turn" || fields[0] == "time"):
+		c.subject = fields[0]
+		c.op = fields[1]
+		if !isOperator(c.op) {
+			err = fmt.Errorf("%q is not one of ==, !=, <, <=, > or >=", c.op)
+		} else if c.subject == "time" {
+			c.duration, err = time.ParseDuration(fields[2])
+		} else {
+			c.value, err = parseCount(fields[2])
+		}
+	default:
+		err = fmt.Errorf("expected population, turn or time compared to a value, population stable for <n> turns or every <n> turns")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("condition %q: %v", s, err)
+	}
+	return c, nil
+}
+
+func isTurns(s string) bool {
+	return s == "turns" || s == "turn"
+}
+
+func isOperator(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// parseCount parses a non-negative number that may end in k or m.
+func parseCount(s string) (int, error) {
+	multiplier := 1
+	if strings.HasSuffix(s, "k") {
+		multiplier, s = 1000, strings.TrimSuffix(s, "k")
+	} else if strings.HasSuffix(s, "m") {
+		multiplier, s = 1000000, strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a count", s)
+	}
+	return n * multiplier, nil
+}
+
+func compare(a int64, op string, b int64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// Holds returns whether the condition holds after turn completed turns. It must be called
+// after every turn, as a stable population is tracked from one call to the next.
+func (c *Condition) Holds(turn, population int, elapsed time.Duration) bool {
+	switch c.subject {
+	case "population":
+		return compare(int64(population), c.op, int64(c.value))
+	case "stable":
+		if population != c.population {
+			c.population = population
+			c.since = turn
+		}
+		return turn-c.since >= c.value
+	case "turn":
+		return compare(int64(turn), c.op, int64(c.value))
+	case "time":
+		return compare(int64(elapsed), c.op, int64(c.duration))
+	default:
+		return turn > 0 && turn%c.value == 0
+	}
+}
+
+// Trigger fires an action each time its condition starts to hold. It is written as
+// "save when <condition>" or "save every <n> turns", saving a snapshot of the world as
+// the 's' key does.
+type Trigger struct {
+	Action    string
+	condition *Condition
+	held      bool
+}
+
+// ParseTrigger parses a trigger such as "save when population > 50k".
+func ParseTrigger(s string) (*Trigger, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) < 2 || fields[0] != "save" {
+		return nil, fmt.Errorf("trigger %q: expected save when <condition> or save every <n> turns", s)
+	}
+	rest := strings.Join(fields[1:], " ")
+	if fields[1] == "when" {
+		rest = strings.Join(fields[2:], " ")
+	}
+	condition, err := ParseCondition(rest)
+	if err != nil {
+		return nil, fmt.Errorf("trigger %q: %v", s, err)
+	}
+	return &Trigger{Action: fields[0], condition: condition}, nil
+}
+
+// Monitor checks the stop conditions and triggers of a run after each turn.
+type Monitor struct {
+	stops    []*Condition
+	triggers []*Trigger
+	start    time.Time
+}
+
+// NewMonitor parses the stop conditions and triggers in p. The wall time of the run starts now.
+func NewMonitor(p Params) (*Monitor, error) {
+	m := &Monitor{start: time.Now()}
+	for _, s := range p.StopWhen {
+		condition, err := ParseCondition(s)
+		if err != nil {
+			return nil, err
+		}
+		m.stops = append(m.stops, condition)
+	}
+	for _, s := range p.Triggers {
+		trigger, err := ParseTrigger(s)
+		if err != nil {
+			return nil, err
+		}
+		m.triggers = append(m.triggers, trigger)
+	}
+	return m, nil
+}
+
+// Check returns whether the run should stop and whether a snapshot should be saved after turn completed turns.
+func (m *Monitor) Check(turn, population int) (stop, save bool) {
+	elapsed := time.Since(m.start)
+	for _, condition := range m.stops {
+		if condition.Holds(turn, population, elapsed) {
+			stop = true
+		}
+	}
+	for _, trigger := range m.triggers {
+		holds := trigger.condition.Holds(turn, population, elapsed)
+		if holds && !trigger.held {
+			save = true
+		}
+		trigger.held = holds
+	}
+	return stop, save
+}
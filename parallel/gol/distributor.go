@@ -13,51 +13,12 @@ type distributorChannels struct {
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
 	ioFilename chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
+	ioOutput   chan<- ioImage
+	ioInput    <-chan [][]uint8
+	ioInputErr <-chan error
 	keyPresses <-chan rune
 }
 
-// worker function to calculate next state for a specific region of the world.
-func worker(c distributorChannels, turn int, startY, endY, startX, endX int, temp_world [][]uint8, world [][]uint8, worldHeight int, worldWidth int, wg *sync.WaitGroup) {
-	defer wg.Done()
-	//initialize cellsFlipped struct
-	cellsFlipped := CellsFlipped{
-		turn,
-		nil,
-	}
-
-	for i := startY; i < endY; i++ {
-		for j := startX; j < endX; j++ {
-			sum := int(temp_world[(i-1+worldHeight)%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[i%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][(j-1+worldWidth)%worldWidth]) +
-				int(temp_world[(i-1+worldHeight)%worldHeight][j%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][j%worldWidth]) +
-				int(temp_world[(i-1+worldHeight)%worldHeight][(j+1)%worldWidth]) +
-				int(temp_world[i%worldHeight][(j+1)%worldWidth]) +
-				int(temp_world[(i+1)%worldHeight][(j+1)%worldWidth])
-
-			if temp_world[i][j] == 255 {
-				if sum < 2*255 || sum > 3*255 {
-					world[i][j] = 0
-					cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{j, i})
-				} else {
-					world[i][j] = temp_world[i][j]
-				}
-			} else {
-				if sum == 3*255 {
-					world[i][j] = 255
-					cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{j, i})
-				} else {
-					world[i][j] = temp_world[i][j]
-				}
-			}
-		}
-	}
-	c.events <- cellsFlipped
-}
-
 // count currentAliveCells function
 func currentAliveCells(imageHeight int, imageWidth int, world [][]uint8) int {
 	var aliveCells int
@@ -71,66 +32,88 @@ func currentAliveCells(imageHeight int, imageWidth int, world [][]uint8) int {
 	return aliveCells
 }
 
-// Save the current state of the board as a PGM image
-func saveCurrentWorld(p Params, c distributorChannels, turn int, currentWorld ImageOutputComplete, world [][]uint8) {
-	currentImageFile := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn)
-	currentWorld = ImageOutputComplete{
-		turn,
-		currentImageFile,
-	}
+// Save the current state of the board as a PGM image. A copy of world is written while the next turns are
+// computed, the io goroutine sends ImageOutputComplete once it has been.
+func saveCurrentWorld(p Params, c distributorChannels, turn int, world [][]uint8) {
 	c.ioCommand <- ioOutput
-	c.ioFilename <- currentImageFile
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			c.ioOutput <- world[y][x]
-		}
+	c.ioOutput <- ioImage{OutputName(p, turn), turn, copyWorld(world)}
+}
+
+// copyWorld returns a copy of world, which can be output while world changes.
+func copyWorld(world [][]uint8) [][]uint8 {
+	copied := make([][]uint8, len(world))
+	for y := range world {
+		copied[y] = append([]uint8(nil), world[y]...)
 	}
-	c.ioCommand <- ioCheckIdle
-	<-c.ioIdle
-	c.events <- currentWorld
+	return copied
+}
+
+// inputFailed reports that the image to start from could not be read, and quits.
+func inputFailed(events chan<- Event, turn int, err error) {
+	events <- ImageInputFailed{turn, err}
+	events <- StateChange{turn, Quitting}
+	close(events)
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
+func distributor(p Params, rule Rule, monitor *Monitor, resume *Checkpoint, c distributorChannels) {
 
 	world := make([][]uint8, p.ImageHeight)
-	temp_world := make([][]uint8, p.ImageHeight)
 	for i := 0; i < p.ImageHeight; i++ {
 		world[i] = make([]uint8, p.ImageWidth)
-		temp_world[i] = make([]uint8, p.ImageWidth)
 	}
+	turn := 0
+	// Unbounded worlds may be replaced by the bounding box of their cells, whose top left cell is at origin
+	var origin util.Cell
+
+	if resume != nil {
+		// Continue from the checkpoint instead of loading the image
+		world = resume.World
+		turn = resume.Turn
+		origin = resume.Origin
+	} else {
+		// Get filename and load initial world state, or the input placed in it
+		if input := p.InputFile(); input != "" {
+			c.ioCommand <- ioInputPattern
+			c.ioFilename <- input
+		} else {
+			filename := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
+			c.ioCommand <- ioInput
+			c.ioFilename <- filename
+		}
+		if err := <-c.ioInputErr; err != nil {
+			inputFailed(c.events, turn, err)
+			return
+		}
 
-	// Get filename and load initial world state
-	filename := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
-	c.ioCommand <- ioInput
-	c.ioFilename <- filename
+		//initializing world
+		world = <-c.ioInput
+		for y := range world {
+			for x, cell := range world[y] {
+				world[y][x] = loadPgmCell(cell, rule)
+			}
+		}
+	}
 
 	//initialize cellsFlipped struct
 	cellsFlipped := CellsFlipped{
-		0,
-		nil,
+		CompletedTurns: turn,
 	}
-	//initializing world
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			cell := <-c.ioInput
-			world[y][x] = cell
-			// Check if cell is alive for cellsFlipped event
-			if cell == 255 {
-				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{x, y})
+	// Check if cell is not dead for cellsFlipped event
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell != 0 {
+				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{x + origin.X, y + origin.Y})
+				if rule.IsGenerations() {
+					cellsFlipped.Deltas = append(cellsFlipped.Deltas, cell)
+				}
 			}
 		}
 	}
 	c.events <- cellsFlipped
 
-	turn := 0
 	c.events <- StateChange{turn, Executing}
 
-	// Copy world to temp_world
-	for y := 0; y < p.ImageHeight; y++ {
-		copy(temp_world[y], world[y])
-	}
-
 	//Start ticker for reporting alive cells every 2 seconds
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
@@ -143,10 +126,13 @@ func distributor(p Params, c distributorChannels) {
 	tickerWg.Add(1)
 	var currentAliveCellCount AliveCellsCount
 	//Save the current state of the board as a PGM image
-	var currentWorld ImageOutputComplete
 	saveCurrentState := make(chan bool)
 	stopCurrentTurn := make(chan bool)
 	pauseExecution := make(chan bool)
+	rewindExecution := make(chan rune)
+	changeSpeed := make(chan rune)
+	toggleRecording := make(chan bool)
+	recording := p.Recording.Start
 	pauseStatus := false
 	var pausemtx sync.Mutex
 	var alivemtx sync.Mutex
@@ -173,6 +159,12 @@ func distributor(p Params, c distributorChannels) {
 						pauseStatus = false
 					}
 					pausemtx.Unlock()
+				} else if key == 'b' || key == 'n' {
+					rewindExecution <- key
+				} else if key == '+' || key == '-' {
+					changeSpeed <- key
+				} else if key == 'r' {
+					toggleRecording <- true
 				}
 			case ticker_close := <-quit_ticker:
 				if ticker_close {
@@ -182,34 +174,114 @@ func distributor(p Params, c distributorChannels) {
 		}
 	}()
 
+	// The BitPacked engine keeps its own world, world is only brought up to date before it is output
+	var bitCells *bitWorld
+	if p.Engine == BitPacked {
+		bitCells = newBitWorld(world, rule, p.Boundary)
+	}
+	// The HashLife engine brings world up to date after every leap of many turns
+	var hashCells *hashLife
+	var scratch [][]uint8
+	if p.Engine == HashLife {
+		hashCells = newHashLife(world, rule)
+		scratch = make([][]uint8, p.ImageHeight)
+		for i := range scratch {
+			scratch[i] = make([]uint8, p.ImageWidth)
+		}
+	}
+	// The Bytes engine runs long-lived workers that each own a strip of the world and exchange halo rows.
+	// world is kept up to date from the cells they flip, and only the tiles around those are recomputed.
+	var pool *workerPool
+	tiles := newActiveTiles(p.ImageWidth, p.ImageHeight, rule, p.Boundary)
+	aliveCount := 0
+	// Cycle detection hashes every generation. The Bytes engine updates the hash from the cells it flips.
+	var cycles *CycleDetector
+	cycleFound := false
+	var worldHash uint64
+	if p.CycleHistory > 0 {
+		cycles = NewCycleDetector(p.CycleHistory)
+		worldHash = HashWorld(world)
+		cycles.Add(turn, worldHash)
+	}
+	// Unbounded worlds are stored sparsely instead. Before output world is replaced by the bounding box
+	// of their cells, whose top left cell is at origin.
+	var sparseCells *sparseWorld
+	if p.Boundary == Unbounded {
+		sparseCells = newSparseWorld(world, origin, rule)
+	} else if p.Engine == Bytes {
+		pool = newWorkerPool(world, rule, p.Boundary, tiles, p.Threads)
+	}
+	// While paused, 'b' steps back through the most recent turns and 'n' forward again. The engines are left
+	// at the latest turn, only world is stepped. The HashLife engine and unbounded worlds keep no history.
+	var hist *history
+	if p.History > 0 && hashCells == nil && sparseCells == nil {
+		hist = newHistory(p.History)
+	}
+
+	// A checkpoint is written each time the turn passes a multiple of p.Checkpoints
+	lastCheckpoint := turn
+
+	// Turns are paced to the target speed, and 'n' runs a single turn while paused
+	throttle := Throttle{TurnsPerSecond: p.TurnsPerSecond}
+	singleStep := false
+
 	// Execute all turns of the Game of Life
-	var wg sync.WaitGroup
 	workerNum := p.Threads
 	for turn < p.Turns {
-		if pauseStatus == false {
-			// Update temp_world state
-			for y := 0; y < p.ImageHeight; y++ {
-				copy(temp_world[y], world[y])
+		if (pauseStatus == false && throttle.Due()) || singleStep {
+			// Catch up with the latest turn if execution resumes after stepping back
+			if hist != nil {
+				for flipped, ok := hist.redo(); ok; flipped, ok = hist.redo() {
+					applyFlips(c, world, flipped, turn-hist.back)
+				}
 			}
 
-			// Distribute work among workers
-			unitY := p.ImageHeight / workerNum
-			wg.Add(workerNum)
-			for i := 1; i <= workerNum; i++ {
-				startY := unitY * (i - 1)
-				endY := unitY * i
-				if i == workerNum {
-					leftY := p.ImageHeight - (i-1)*unitY
-					go worker(c, turn, startY, startY+leftY, 0, p.ImageWidth, temp_world, world, p.ImageHeight, p.ImageWidth, &wg)
-				} else {
-					go worker(c, turn, startY, endY, 0, p.ImageWidth, temp_world, world, p.ImageHeight, p.ImageWidth, &wg)
+			completedTurns := 1
+			var flipped []CellsFlipped
+			if bitCells != nil {
+				flipped = bitCells.step(turn, workerNum)
+				if cycles != nil {
+					worldHash = bitCells.hash()
+				}
+			} else if hashCells != nil {
+				s := leap(p.Turns-turn, p.HashStep)
+				hashCells.run(c, turn, s, world, scratch)
+				completedTurns = 1 << uint(s)
+				aliveCount = currentAliveCells(p.ImageHeight, p.ImageWidth, world)
+			} else if sparseCells != nil {
+				flipped = sparseCells.step(turn, workerNum)
+				aliveCount = sparseCells.alive
+				if cycles != nil {
+					worldHash = sparseCells.hash()
+				}
+			} else {
+				aliveCount = 0
+				for _, report := range pool.step(turn) {
+					for k, cell := range report.flipped.Cells {
+						old := world[cell.Y][cell.X]
+						if report.flipped.Deltas != nil {
+							world[cell.Y][cell.X] ^= report.flipped.Deltas[k]
+						} else {
+							world[cell.Y][cell.X] ^= 255
+						}
+						if cycles != nil {
+							worldHash ^= CellHash(cell.X, cell.Y, old) ^ CellHash(cell.X, cell.Y, world[cell.Y][cell.X])
+						}
+						tiles.markChanged(cell.X, cell.Y)
+					}
+					flipped = append(flipped, report.flipped)
+					aliveCount += report.alive
 				}
+				tiles.advance()
+			}
+			for _, cellsFlipped := range flipped {
+				c.events <- cellsFlipped
+			}
+			if hist != nil {
+				hist.record(flipped)
 			}
 
-			// Wait for all workers to complete
-			wg.Wait()
-
-			turn++
+			turn += completedTurns
 			//Send TurnComplete events
 			turnComplete := TurnComplete{
 				turn,
@@ -218,11 +290,57 @@ func distributor(p Params, c distributorChannels) {
 
 			// Report alive cells after each turn
 			alivemtx.Lock()
-			currentAliveCellCount = AliveCellsCount{
-				turn,
-				currentAliveCells(p.ImageHeight, p.ImageWidth, world),
+			if bitCells != nil {
+				currentAliveCellCount = AliveCellsCount{turn, bitCells.aliveCount()}
+			} else {
+				currentAliveCellCount = AliveCellsCount{
+					turn,
+					aliveCount,
+				}
 			}
 			alivemtx.Unlock()
+
+			if cycles != nil && !cycleFound {
+				if firstSeen, ok := cycles.Add(turn, worldHash); ok {
+					cycleFound = true
+					c.events <- CycleDetected{turn, turn - firstSeen, firstSeen}
+					if p.StopOnCycle {
+						break
+					}
+				}
+			}
+
+			if p.Checkpoints > 0 && turn/p.Checkpoints > lastCheckpoint/p.Checkpoints {
+				if bitCells != nil {
+					bitCells.unpack(world)
+				}
+				if sparseCells != nil {
+					world, origin = sparseCells.crop()
+				}
+				checkpoint := Checkpoint{Turn: turn, Rule: rule.String(), Boundary: p.Boundary, Origin: origin, World: world}
+				util.Check(WriteCheckpoint(CheckpointPath(p), checkpoint))
+				lastCheckpoint = turn
+			}
+
+			// Save snapshots and finish early as the conditions of the run say
+			stop, save := monitor.Check(turn, currentAliveCellCount.CellsCount)
+			if save {
+				if bitCells != nil {
+					bitCells.unpack(world)
+				}
+				if sparseCells != nil {
+					world, origin = sparseCells.crop()
+				}
+				saveCurrentWorld(p, c, turn, world)
+			}
+			if stop {
+				break
+			}
+
+			if singleStep {
+				singleStep = false
+				c.events <- StateChange{turn, Paused}
+			}
 		}
 
 		//KeyPress
@@ -230,17 +348,39 @@ func distributor(p Params, c distributorChannels) {
 		case save := <-saveCurrentState:
 			{
 				if save == true {
-					saveCurrentWorld(p, c, turn, currentWorld, world)
+					// After stepping back world is the one shown, not the latest
+					shownTurn := turn
+					if hist != nil {
+						shownTurn -= hist.back
+					}
+					if bitCells != nil && shownTurn == turn {
+						bitCells.unpack(world)
+					}
+					if sparseCells != nil {
+						world, origin = sparseCells.crop()
+					}
+					saveCurrentWorld(p, c, shownTurn, world)
 				}
 			}
 		case stop := <-stopCurrentTurn:
 			{
 				if stop == true {
+					if hist != nil {
+						for flipped, ok := hist.redo(); ok; flipped, ok = hist.redo() {
+							applyFlips(c, world, flipped, turn-hist.back)
+						}
+					}
+					if bitCells != nil {
+						bitCells.unpack(world)
+					}
+					if sparseCells != nil {
+						world, origin = sparseCells.crop()
+					}
 					var aliveCells []util.Cell
-					for i := 0; i < p.ImageHeight; i++ {
-						for j := 0; j < p.ImageWidth; j++ {
+					for i := range world {
+						for j := range world[i] {
 							if world[i][j] == 255 {
-								aliveCells = append(aliveCells, util.Cell{j, i})
+								aliveCells = append(aliveCells, util.Cell{j + origin.X, i + origin.Y})
 							}
 						}
 					}
@@ -249,10 +389,13 @@ func distributor(p Params, c distributorChannels) {
 						aliveCells,
 					}
 					c.events <- final
-					saveCurrentWorld(p, c, turn, currentWorld, world)
+					saveCurrentWorld(p, c, turn, world)
 					c.ioCommand <- ioCheckIdle
 					<-c.ioIdle
 					c.events <- StateChange{turn, Quitting}
+					if pool != nil {
+						pool.stop()
+					}
 					return
 				}
 			}
@@ -266,19 +409,69 @@ func distributor(p Params, c distributorChannels) {
 				}
 				pausemtx.Unlock()
 			}
+		case key := <-rewindExecution:
+			{
+				pausemtx.Lock()
+				if pauseStatus && key == 'n' && (hist == nil || hist.back == 0) {
+					// Nothing to step forward through, run the next turn instead
+					singleStep = true
+				} else if pauseStatus && hist != nil {
+					if bitCells != nil && hist.back == 0 {
+						bitCells.unpack(world)
+					}
+					var flipped []CellsFlipped
+					var ok bool
+					if key == 'b' {
+						flipped, ok = hist.undo()
+					} else {
+						flipped, ok = hist.redo()
+					}
+					if ok {
+						applyFlips(c, world, flipped, turn-hist.back)
+						c.events <- StateChange{turn - hist.back, Paused}
+					}
+				}
+				pausemtx.Unlock()
+			}
+		case key := <-changeSpeed:
+			{
+				if key == '+' {
+					throttle.TurnsPerSecond = FasterSpeed(throttle.TurnsPerSecond)
+				} else {
+					throttle.TurnsPerSecond = SlowerSpeed(throttle.TurnsPerSecond)
+				}
+				c.events <- SpeedChanged{turn, throttle.TurnsPerSecond}
+			}
+		case <-toggleRecording:
+			recording = !recording
+			c.events <- RecordingToggled{turn, recording}
 		default:
+			// Wait for the next turn rather than spin when it is not due yet
+			if pauseStatus == false {
+				throttle.Wait(10 * time.Millisecond)
+			}
 		}
 
 	}
 
 	quit_ticker <- true
+	if pool != nil {
+		pool.stop()
+	}
+
+	if bitCells != nil {
+		bitCells.unpack(world)
+	}
+	if sparseCells != nil {
+		world, origin = sparseCells.crop()
+	}
 
 	// Report the final state using FinalTurnCompleteEvent
 	var aliveCells []util.Cell
-	for i := 0; i < p.ImageHeight; i++ {
-		for j := 0; j < p.ImageWidth; j++ {
+	for i := range world {
+		for j := range world[i] {
 			if world[i][j] == 255 {
-				aliveCells = append(aliveCells, util.Cell{j, i})
+				aliveCells = append(aliveCells, util.Cell{j + origin.X, i + origin.Y})
 			}
 		}
 	}
@@ -289,20 +482,9 @@ func distributor(p Params, c distributorChannels) {
 	}
 	c.events <- final
 
-	//Output final image
+	//Output final image, world no longer changes so it is not copied
 	c.ioCommand <- ioOutput
-	finalImgFilename := fmt.Sprintf("%vx%vx%v", p.ImageWidth, p.ImageHeight, turn)
-	c.ioFilename <- finalImgFilename
-	finalWorld := ImageOutputComplete{
-		turn,
-		finalImgFilename,
-	}
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			c.ioOutput <- world[y][x]
-		}
-	}
-	c.events <- finalWorld
+	c.ioOutput <- ioImage{OutputName(p, turn), turn, world}
 
 	//Make sure that the IO has finished any output before exiting
 	c.ioCommand <- ioCheckIdle
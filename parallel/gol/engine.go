@@ -0,0 +1,88 @@
+package gol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Engine selects how the distributor computes each turn.
+type Engine int
+
+const (
+	// Bytes stores a byte per cell and counts each cell's neighbours. It is the default
+	// and supports every rule.
+	Bytes Engine = iota
+	// BitPacked stores 64 cells per uint64 and counts neighbours for a whole word at
+	// a time with bit-sliced adders. It only supports Life-like rules.
+	BitPacked
+	// HashLife memoises the evolution of a quadtree, so it can advance many turns at
+	// once. It only supports Life-like rules on a torus whose sides are powers of two.
+	HashLife
+)
+
+var engineNames = map[Engine]string{
+	Bytes:     "bytes",
+	BitPacked: "bits",
+	HashLife:  "hashlife",
+}
+
+// ParseEngine parses an engine by name: bytes, bits or hashlife. An empty name parses to Bytes.
+func ParseEngine(s string) (Engine, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return Bytes, nil
+	}
+	for engine, name := range engineNames {
+		if name == s {
+			return engine, nil
+		}
+	}
+	return Bytes, fmt.Errorf("engine %q: expected one of bytes, bits or hashlife", s)
+}
+
+func (e Engine) String() string {
+	if name, ok := engineNames[e]; ok {
+		return name
+	}
+	return "Incorrect Engine"
+}
+
+// Supports returns an error if the engine cannot run the rule with the given parameters.
+func (e Engine) Supports(p Params, rule Rule) error {
+	if p.Boundary == Unbounded {
+		if e != Bytes {
+			return fmt.Errorf("engine %v does not support the unbounded boundary", e)
+		}
+		if rule.IsLargerThanLife() {
+			return fmt.Errorf("the unbounded boundary does not support Larger than Life rules, not %v", rule)
+		}
+		if rule.born(0) {
+			return fmt.Errorf("the unbounded boundary does not support rules where cells are born with no neighbours, not %v", rule)
+		}
+	}
+	if e == Bytes {
+		return nil
+	}
+	if rule.IsGenerations() || rule.IsLargerThanLife() {
+		return fmt.Errorf("engine %v only supports Life-like rules, not %v", e, rule)
+	}
+	if e == HashLife {
+		if p.CycleHistory > 0 {
+			return fmt.Errorf("engine %v advances many turns at once and does not support cycle detection", e)
+		}
+		if len(p.StopWhen) > 0 || len(p.Triggers) > 0 {
+			return fmt.Errorf("engine %v advances many turns at once and does not support stop conditions or triggers", e)
+		}
+		if p.Boundary != Torus {
+			return fmt.Errorf("engine %v only supports the torus boundary, not %v", e, p.Boundary)
+		}
+		if !isPowerOfTwo(p.ImageWidth) || !isPowerOfTwo(p.ImageHeight) {
+			return fmt.Errorf("engine %v only supports worlds whose sides are powers of two, not %vx%v", e, p.ImageWidth, p.ImageHeight)
+		}
+	}
+	return nil
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
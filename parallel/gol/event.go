@@ -29,6 +29,13 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// `ImageInputFailed` is an Event notifying the user that the image, pattern or checkpoint to start from could not be read.
+// Err is a *PgmError for PGM images. No turns are run, Quitting follows and the events are closed.
+type ImageInputFailed struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -58,9 +65,12 @@ type CellFlipped struct { // implements Event
 // You can send many times of `CellsFlipped` event in a turn, i.e., each worker could send `CellsFlipped`.
 // **Please be careful not to send `CellFlipped` and `CellsFlipped` at the same time, as they may conflict.**
 // Choose one of them.
+// With a Generations rule a cell can change value without toggling between dead and alive.
+// Deltas then holds, for each cell in Cells, the XOR of its old and new value; nil means every cell toggled.
 type CellsFlipped struct { // implements Event
 	CompletedTurns int
 	Cells          []util.Cell
+	Deltas         []uint8
 }
 
 // `TurnComplete` is an Event notifying the GUI about turn completion.
@@ -78,6 +88,36 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// `CycleDetected` is an Event notifying the user that the world after Turn turns is the same as after FirstSeen turns,
+// so from then on it repeats every Period turns. It is only sent once, when cycle detection is enabled.
+type CycleDetected struct { // implements Event
+	Turn      int
+	Period    int
+	FirstSeen int
+}
+
+// `SpeedChanged` is an Event notifying the user about the target speed of execution.
+// This Event should be sent every time the speed is changed with '+' or '-'. 0 turns per second means as fast as possible.
+type SpeedChanged struct { // implements Event
+	CompletedTurns int
+	TurnsPerSecond int
+}
+
+// `RecordingToggled` is an Event notifying the user that recording started or stopped.
+// This Event should be sent every time 'r' is pressed, Recording is whether the run is now recorded.
+type RecordingToggled struct { // implements Event
+	CompletedTurns int
+	Recording      bool
+}
+
+// `RecordingComplete` is an Event notifying the user that Frames frames of the run were written as an animated GIF.
+// Filename is without its .gif extension, like that of ImageOutputComplete.
+type RecordingComplete struct { // implements Event
+	CompletedTurns int
+	Filename       string
+	Frames         int
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -109,6 +149,25 @@ func (event AliveCellsCount) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event RecordingToggled) String() string {
+	if event.Recording {
+		return "Recording Started"
+	}
+	return "Recording Stopped"
+}
+
+func (event RecordingToggled) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event RecordingComplete) String() string {
+	return fmt.Sprintf("File %v Recording of %v Frames Done", event.Filename, event.Frames)
+}
+
+func (event RecordingComplete) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event ImageOutputComplete) String() string {
 	return fmt.Sprintf("File %v Output Done", event.Filename)
 }
@@ -117,6 +176,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event ImageInputFailed) String() string {
+	return fmt.Sprintf("Image Input Failed: %v", event.Err)
+}
+
+func (event ImageInputFailed) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return ""
 }
@@ -149,6 +216,25 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CycleDetected) String() string {
+	return fmt.Sprintf("Cycle of period %v first seen at turn %v", event.Period, event.FirstSeen)
+}
+
+func (event CycleDetected) GetCompletedTurns() int {
+	return event.Turn
+}
+
+func (event SpeedChanged) String() string {
+	if event.TurnsPerSecond <= 0 {
+		return "Speed unlimited"
+	}
+	return fmt.Sprintf("Speed %v turns/sec", event.TurnsPerSecond)
+}
+
+func (event SpeedChanged) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
@@ -0,0 +1,274 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// hashLife is the world of the HashLife engine: a quadtree whose nodes are canonicalised,
+// so identical regions share a node, and whose results are memoised.
+//
+// HashLife works on an infinite plane. A torus is an infinite plane that repeats the world,
+// so the world is tiled into a square of side size, a power of two, held by root.
+// Tiling root further gives nodes big enough to advance any power of two turns at once.
+type hashLife struct {
+	rule          Rule
+	width, height int
+	size          int // side of root, the smallest power of two at least width and height
+	level         int // log2(size)
+	root          *node
+	// root holds the plane starting at (offsetX, offsetY), which moves as the tiling is stepped
+	offsetX, offsetY int
+
+	nodes       map[quad]*node
+	dead, alive *node
+	grid        [][]uint8 // scratch for unpacking root
+	maxNodes    int
+}
+
+// node is a square of side 2^level. Level 0 nodes are single cells.
+type node struct {
+	quad
+	level int
+	// The memoised centre of this node advanced 2^resultStep turns
+	result     *node
+	resultStep int
+}
+
+type quad struct {
+	nw, ne, sw, se *node
+}
+
+// hashLifeMaxNodes is the number of nodes after which the cache is thrown away and rebuilt.
+const hashLifeMaxNodes = 1 << 21
+
+func newHashLife(world [][]uint8, rule Rule) *hashLife {
+	height := len(world)
+	width := len(world[0])
+	h := &hashLife{
+		rule:     rule,
+		width:    width,
+		height:   height,
+		size:     2,
+		level:    1,
+		maxNodes: hashLifeMaxNodes,
+	}
+	for h.size < width || h.size < height {
+		h.size *= 2
+		h.level++
+	}
+	h.grid = make([][]uint8, h.size)
+	for y := range h.grid {
+		h.grid[y] = make([]uint8, h.size)
+	}
+	h.build(world)
+	return h
+}
+
+// build starts a new cache holding just the world.
+func (h *hashLife) build(world [][]uint8) {
+	h.nodes = make(map[quad]*node)
+	h.dead = &node{level: 0}
+	h.alive = &node{level: 0}
+	h.offsetX, h.offsetY = 0, 0
+	h.root = h.buildNode(world, 0, 0, h.level)
+}
+
+func (h *hashLife) buildNode(world [][]uint8, x, y, level int) *node {
+	if level == 0 {
+		if world[y%h.height][x%h.width] == 255 {
+			return h.alive
+		}
+		return h.dead
+	}
+	half := 1 << uint(level-1)
+	return h.join(
+		h.buildNode(world, x, y, level-1),
+		h.buildNode(world, x+half, y, level-1),
+		h.buildNode(world, x, y+half, level-1),
+		h.buildNode(world, x+half, y+half, level-1),
+	)
+}
+
+// join returns the canonical node made of four nodes one level down.
+func (h *hashLife) join(nw, ne, sw, se *node) *node {
+	q := quad{nw, ne, sw, se}
+	if n, ok := h.nodes[q]; ok {
+		return n
+	}
+	n := &node{quad: q, level: nw.level + 1}
+	h.nodes[q] = n
+	return n
+}
+
+func (h *hashLife) centre(n *node) *node {
+	return h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+func (h *hashLife) centreHorizontal(w, e *node) *node {
+	return h.join(w.ne, e.nw, w.se, e.sw)
+}
+
+func (h *hashLife) centreVertical(n, s *node) *node {
+	return h.join(n.sw, n.se, s.nw, s.ne)
+}
+
+// step returns the centre of n, one level down, advanced 2^s turns. s must be at most n.level-2.
+func (h *hashLife) step(n *node, s int) *node {
+	if n.result != nil && n.resultStep == s {
+		return n.result
+	}
+	var result *node
+	if n.level == 2 {
+		result = h.base(n)
+	} else {
+		n00, n01, n02 := n.nw, h.centreHorizontal(n.nw, n.ne), n.ne
+		n10, n11, n12 := h.centreVertical(n.nw, n.sw), h.centre(n), h.centreVertical(n.ne, n.se)
+		n20, n21, n22 := n.sw, h.centreHorizontal(n.sw, n.se), n.se
+		if s == n.level-2 {
+			// Advance 2^(s-1) turns twice, each time from overlapping nodes one level down
+			a, b, c := h.step(n00, s-1), h.step(n01, s-1), h.step(n02, s-1)
+			d, e, f := h.step(n10, s-1), h.step(n11, s-1), h.step(n12, s-1)
+			g, i, j := h.step(n20, s-1), h.step(n21, s-1), h.step(n22, s-1)
+			result = h.join(
+				h.step(h.join(a, b, d, e), s-1),
+				h.step(h.join(b, c, e, f), s-1),
+				h.step(h.join(d, e, g, i), s-1),
+				h.step(h.join(e, f, i, j), s-1),
+			)
+		} else {
+			// Advance 2^s turns once, then take the centres
+			a, b, c := h.step(n00, s), h.step(n01, s), h.step(n02, s)
+			d, e, f := h.step(n10, s), h.step(n11, s), h.step(n12, s)
+			g, i, j := h.step(n20, s), h.step(n21, s), h.step(n22, s)
+			result = h.join(
+				h.centre(h.join(a, b, d, e)),
+				h.centre(h.join(b, c, e, f)),
+				h.centre(h.join(d, e, g, i)),
+				h.centre(h.join(e, f, i, j)),
+			)
+		}
+	}
+	n.result, n.resultStep = result, s
+	return result
+}
+
+// base advances the centre 2x2 cells of a 4x4 node one turn.
+func (h *hashLife) base(n *node) *node {
+	var cells [4][4]bool
+	for y, row := range [2][2]*node{{n.nw, n.ne}, {n.sw, n.se}} {
+		for x, quarter := range row {
+			cells[2*y][2*x] = quarter.nw == h.alive
+			cells[2*y][2*x+1] = quarter.ne == h.alive
+			cells[2*y+1][2*x] = quarter.sw == h.alive
+			cells[2*y+1][2*x+1] = quarter.se == h.alive
+		}
+	}
+	next := func(x, y int) *node {
+		neighbours := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0) && cells[y+dy][x+dx] {
+					neighbours++
+				}
+			}
+		}
+		var cell uint8
+		if cells[y][x] {
+			cell = 255
+		}
+		if h.rule.Step(cell, neighbours) == 255 {
+			return h.alive
+		}
+		return h.dead
+	}
+	return h.join(next(1, 1), next(2, 1), next(1, 2), next(2, 2))
+}
+
+// tile returns a node of the given level covering the plane repeating root, starting at the same place.
+func (h *hashLife) tile(level int) *node {
+	n := h.root
+	for l := h.level; l < level; l++ {
+		n = h.join(n, n, n, n)
+	}
+	return n
+}
+
+// advance moves the world on 2^s turns.
+func (h *hashLife) advance(s int) {
+	if s < h.level {
+		// The 2x2 tiling can advance up to 2^(level-1) turns, its centre is offset by size/2
+		h.root = h.step(h.tile(h.level+1), s)
+		h.offsetX = (h.offsetX + h.size/2) % h.size
+		h.offsetY = (h.offsetY + h.size/2) % h.size
+	} else {
+		// The centre of a bigger tiling is offset by a multiple of size, any quarter of it will do
+		n := h.step(h.tile(s+2), s)
+		for n.level > h.level {
+			n = n.nw
+		}
+		h.root = n
+	}
+	if len(h.nodes) > h.maxNodes {
+		world := make([][]uint8, h.height)
+		for y := range world {
+			world[y] = make([]uint8, h.width)
+		}
+		h.unpack(world)
+		h.build(world)
+	}
+}
+
+// unpack writes the cells of the world into a byte per cell world.
+func (h *hashLife) unpack(world [][]uint8) {
+	h.fill(h.root, 0, 0)
+	for y := 0; y < h.height; y++ {
+		row := h.grid[((y-h.offsetY)%h.size+h.size)%h.size]
+		for x := 0; x < h.width; x++ {
+			world[y][x] = row[((x-h.offsetX)%h.size+h.size)%h.size]
+		}
+	}
+}
+
+func (h *hashLife) fill(n *node, x, y int) {
+	if n.level == 0 {
+		if n == h.alive {
+			h.grid[y][x] = 255
+		} else {
+			h.grid[y][x] = 0
+		}
+		return
+	}
+	half := 1 << uint(n.level-1)
+	h.fill(n.nw, x, y)
+	h.fill(n.ne, x+half, y)
+	h.fill(n.sw, x, y+half)
+	h.fill(n.se, x+half, y+half)
+}
+
+// leap returns s such that advancing 2^s turns does not pass the last turn,
+// as many turns as possible but at most 2^maxStep if maxStep is positive.
+// Repeated leaps reach any number of turns through decreasing powers of two.
+func leap(remaining, maxStep int) int {
+	s := 0
+	for 1<<uint(s+1) <= remaining && (maxStep <= 0 || s < maxStep) {
+		s++
+	}
+	return s
+}
+
+// run advances the world 2^s turns, brings world up to date and sends the cells that flipped.
+// scratch must be the same size as world.
+func (h *hashLife) run(c distributorChannels, turn, s int, world, scratch [][]uint8) {
+	h.advance(s)
+	h.unpack(scratch)
+	cellsFlipped := CellsFlipped{
+		CompletedTurns: turn,
+	}
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] != scratch[y][x] {
+				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{X: x, Y: y})
+			}
+		}
+		copy(world[y], scratch[y])
+	}
+	c.events <- cellsFlipped
+}
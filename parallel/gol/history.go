@@ -0,0 +1,58 @@
+package gol
+
+// history keeps the cells flipped in each of the most recent turns, so the world can be
+// stepped back and forward through them. Applying the flips of a turn again undoes it,
+// as every flip is an XOR of the old and new value of a cell.
+type history struct {
+	limit int
+	turns [][]CellsFlipped // flips of each turn, oldest first
+	back  int              // how many of the turns have been undone
+}
+
+func newHistory(limit int) *history {
+	return &history{limit: limit}
+}
+
+// record adds the flips of the turn just completed, forgetting the oldest turn beyond the limit.
+// It must only be called when no turn is undone.
+func (h *history) record(flipped []CellsFlipped) {
+	h.turns = append(h.turns, flipped)
+	if len(h.turns) > h.limit {
+		h.turns[0] = nil
+		h.turns = h.turns[1:]
+	}
+}
+
+// undo returns the flips of the latest turn not yet undone, or false if history goes back no further.
+func (h *history) undo() ([]CellsFlipped, bool) {
+	if h.back == len(h.turns) {
+		return nil, false
+	}
+	h.back++
+	return h.turns[len(h.turns)-h.back], true
+}
+
+// redo returns the flips of the earliest undone turn, or false if no turn is undone.
+func (h *history) redo() ([]CellsFlipped, bool) {
+	if h.back == 0 {
+		return nil, false
+	}
+	flipped := h.turns[len(h.turns)-h.back]
+	h.back--
+	return flipped, true
+}
+
+// applyFlips applies flips to world, undoing or redoing a turn, and sends them again so
+// the window follows. The events report turn, the turn the world moved to.
+func applyFlips(c distributorChannels, world [][]uint8, flipped []CellsFlipped, turn int) {
+	for _, cellsFlipped := range flipped {
+		for k, cell := range cellsFlipped.Cells {
+			if cellsFlipped.Deltas != nil {
+				world[cell.Y][cell.X] ^= cellsFlipped.Deltas[k]
+			} else {
+				world[cell.Y][cell.X] ^= 255
+			}
+		}
+		c.events <- CellsFlipped{turn, cellsFlipped.Cells, cellsFlipped.Deltas}
+	}
+}
@@ -1,10 +1,11 @@
 package gol
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -12,10 +13,23 @@ import (
 type ioChannels struct {
 	command <-chan ioCommand
 	idle    chan<- bool
+	events  chan<- Event // ImageOutputComplete is sent once an image has been written
 
 	filename <-chan string
-	output   <-chan uint8
-	input    chan<- uint8
+	output   <-chan ioImage
+	input    chan<- [][]uint8
+	inputErr chan<- error // nil before the world of an image that was read, or why it could not be
+}
+
+// ioQueueLength is how many images may wait to be written before outputting another blocks the distributor.
+const ioQueueLength = 4
+
+// ioImage is an image to output. World is a copy the distributor no longer changes, so it is written while
+// the next turns are computed. Unbounded worlds are cropped, so each image has its own size.
+type ioImage struct {
+	filename string
+	turn     int
+	world    [][]uint8
 }
 
 // ioState is the internal ioState of the io goroutine.
@@ -33,95 +47,124 @@ type ioCommand uint8
 //	ioOutput 	= 0
 //	ioInput 	= 1
 //	ioCheckIdle = 2
+//	ioInputPattern = 3
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioInputPattern
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
+// writePgmImage receives a world and writes it to a pgm file, or in the output format.
 func (io *ioState) writePgmImage() {
 	_ = os.Mkdir("out", os.ModePerm)
 
-	// Request a filename from the distributor.
-	filename := <-io.channels.filename
-
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
-
-	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
-	}
+	// Receive the image from the distributor.
+	image := <-io.channels.output
+	filename, world := image.filename, image.world
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
-		}
+	width, height := 0, len(world)
+	if height > 0 {
+		width = len(world[0])
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
+	if io.params.OutputFormat != PGM {
+		io.writePatternImage(filename, world)
+	} else {
+		file, ioError := createOutput(filename + ".pgm")
+		util.Check(ioError)
+		defer file.Close()
+
+		writer := bufio.NewWriter(file)
+		_, _ = writer.WriteString("P5\n")
+		//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
+		_, _ = writer.WriteString(strconv.Itoa(width))
+		_, _ = writer.WriteString(" ")
+		_, _ = writer.WriteString(strconv.Itoa(height))
+		_, _ = writer.WriteString("\n")
+		_, _ = writer.WriteString(strconv.Itoa(255))
+		_, _ = writer.WriteString("\n")
+
+		for y := 0; y < height; y++ {
+			_, ioError = writer.Write(world[y])
 			util.Check(ioError)
 		}
+
+		util.Check(writer.Flush())
+		ioError = file.Sync()
+		util.Check(ioError)
+
+		fmt.Println("File", filename, "output done!")
 	}
 
-	ioError = file.Sync()
+	io.channels.events <- ImageOutputComplete{image.turn, filename}
+}
+
+// writePatternImage writes a world to a file in the output format, which is not pgm.
+func (io *ioState) writePatternImage(filename string, world [][]uint8) {
+	rule, ioError := ParseRule(io.params.Rule)
+	util.Check(ioError)
+
+	file, ioError := createOutput(filename + io.params.OutputFormat.Extension())
 	util.Check(ioError)
+	defer file.Close()
+
+	util.Check(WritePattern(file, world, rule, io.params.OutputFormat))
+	util.Check(file.Sync())
 
 	fmt.Println("File", filename, "output done!")
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// createOutput creates the file name in out/, and the directories of the output name template it is in.
+func createOutput(name string) (*os.File, error) {
+	path := filepath.Join("out", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// readPatternImage opens an image or pattern file of any format and sends it, placed in the world.
+func (io *ioState) readPatternImage() {
 
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	data, ioError := os.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
-
-	fields := strings.Fields(string(data))
-
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	var world [][]uint8
+	pattern, ioError := ReadPatternFile(filename)
+	if ioError == nil {
+		var rule Rule
+		rule, ioError = ParseRule(io.params.Rule)
+		if ioError == nil {
+			world, ioError = pattern.Place(io.params.ImageWidth, io.params.ImageHeight, io.params.Offset, rule)
+		}
 	}
-
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	io.channels.inputErr <- ioError
+	if ioError != nil {
+		return
 	}
+	io.channels.input <- world
 
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
-	}
+	fmt.Println("File", filename, "input done!")
+}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
-	}
+// readPgmImage opens a pgm file and sends its grey levels as the world.
+func (io *ioState) readPgmImage() {
 
-	image := []byte(fields[4])
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
 
-	for _, b := range image {
-		io.channels.input <- b
+	path := "images/" + filename + ".pgm"
+	image, ioError := ReadPgmFile(path)
+	if ioError == nil && (len(image) != io.params.ImageHeight || len(image[0]) != io.params.ImageWidth) {
+		ioError = &PgmError{path, fmt.Errorf("image is %vx%v, expected %vx%v",
+			len(image[0]), len(image), io.params.ImageWidth, io.params.ImageHeight)}
+	}
+	io.channels.inputErr <- ioError
+	if ioError != nil {
+		return
 	}
+	io.channels.input <- image
 
 	fmt.Println("File", filename, "input done!")
 }
@@ -138,6 +181,8 @@ func startIo(p Params, c ioChannels) {
 		switch command {
 		case ioInput:
 			io.readPgmImage()
+		case ioInputPattern:
+			io.readPatternImage()
 		case ioOutput:
 			io.writePgmImage()
 		case ioCheckIdle:
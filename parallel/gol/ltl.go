@@ -0,0 +1,200 @@
+package gol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxRange is the largest neighbourhood range accepted for Larger than Life rules.
+const MaxRange = 50
+
+// parseLargerThanLife parses a Larger than Life rule in Golly notation, e.g.
+// "R5,C0,M1,S34..58,B34..45,NM". R is the range, C the number of states (0 or 2
+// without refractory states), M1 counts the cell itself, S and B are the survival
+// and birth intervals and NM or NN select a Moore or von Neumann neighbourhood.
+func parseLargerThanLife(s string) (Rule, error) {
+	rule := Rule{}
+	seen := map[byte]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return Rule{}, fmt.Errorf("rule %q: empty part", s)
+		}
+		key := part[0] | 0x20 // lower case
+		value := part[1:]
+		if seen[key] {
+			return Rule{}, fmt.Errorf("rule %q: %c given twice", s, part[0])
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case 'r':
+			rule.Range, err = strconv.Atoi(value)
+			if err == nil && (rule.Range < 1 || rule.Range > MaxRange) {
+				err = fmt.Errorf("range must be between 1 and %v", MaxRange)
+			}
+		case 'c':
+			rule.States, err = strconv.Atoi(value)
+			if err == nil && (rule.States < 0 || rule.States == 1 || rule.States > 256) {
+				err = fmt.Errorf("number of states must be 0 or between 2 and 256")
+			}
+		case 'm':
+			if value != "0" && value != "1" {
+				err = fmt.Errorf("M must be 0 or 1")
+			}
+			rule.Middle = value == "1"
+		case 's':
+			rule.SurviveMin, rule.SurviveMax, err = parseInterval(value)
+		case 'b':
+			rule.BirthMin, rule.BirthMax, err = parseInterval(value)
+		case 'n':
+			switch strings.ToLower(value) {
+			case "m":
+				rule.VonNeumann = false
+			case "n":
+				rule.VonNeumann = true
+			default:
+				err = fmt.Errorf("neighbourhood must be NM (Moore) or NN (von Neumann)")
+			}
+		default:
+			err = fmt.Errorf("unknown part")
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %q: part %q: %v", s, part, err)
+		}
+	}
+	for _, key := range []byte{'r', 's', 'b'} {
+		if !seen[key] {
+			return Rule{}, fmt.Errorf("rule %q: missing %c part", s, key-0x20)
+		}
+	}
+	return rule, nil
+}
+
+// parseInterval parses "min..max" or a single count.
+func parseInterval(s string) (int, int, error) {
+	bounds := strings.SplitN(s, "..", 2)
+	min, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	max := min
+	if len(bounds) == 2 {
+		if max, err = strconv.Atoi(bounds[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid interval")
+	}
+	return min, max, nil
+}
+
+// IsLargerThanLife returns whether the rule uses an extended neighbourhood.
+func (r Rule) IsLargerThanLife() bool {
+	return r.Range > 0
+}
+
+func (r Rule) largerThanLifeString() string {
+	middle, shape := 0, "M"
+	if r.Middle {
+		middle = 1
+	}
+	if r.VonNeumann {
+		shape = "N"
+	}
+	return fmt.Sprintf("R%v,C%v,M%v,S%v..%v,B%v..%v,N%v",
+		r.Range, r.States, middle, r.SurviveMin, r.SurviveMax, r.BirthMin, r.BirthMax, shape)
+}
+
+// CountNeighbours returns the number of alive cells in the neighbourhood of every cell
+// in rows startY to endY-1 and columns startX to endX-1, indexed from (startX, startY).
+// Only the world rows within Range of the strip are read, so workers can each count
+// their own strip. Cells beyond the edges of the world follow the boundary. Moore neighbourhoods use sliding window sums, von Neumann ones
+// use prefix sums of each row, so the cost per cell does not grow with the square of the range.
+func (r Rule) CountNeighbours(world [][]uint8, boundary Boundary, startY, endY, startX, endX int) [][]int {
+	if r.VonNeumann {
+		return r.countVonNeumann(world, boundary, startY, endY, startX, endX)
+	}
+	return r.countMoore(world, boundary, startY, endY, startX, endX)
+}
+
+// alive returns 1 if the cell at (x, y) is alive, following the boundary beyond the edges.
+func alive(world [][]uint8, boundary Boundary, x, y int) int {
+	return int(boundary.Cell(world, x, y) / 255)
+}
+
+func (r Rule) countMoore(world [][]uint8, boundary Boundary, startY, endY, startX, endX int) [][]int {
+	R := r.Range
+	width := endX - startX
+	// colSums[k] is the number of alive cells in column startX-R+k within R rows of the current row
+	colSums := make([]int, width+2*R)
+	addRow := func(y, sign int) {
+		for k := range colSums {
+			colSums[k] += sign * alive(world, boundary, startX-R+k, y)
+		}
+	}
+	for dy := -R; dy <= R; dy++ {
+		addRow(startY+dy, 1)
+	}
+
+	counts := make([][]int, endY-startY)
+	for y := startY; y < endY; y++ {
+		if y > startY {
+			addRow(y+R, 1)
+			addRow(y-1-R, -1)
+		}
+		row := make([]int, width)
+		window := 0
+		for k := 0; k <= 2*R; k++ {
+			window += colSums[k]
+		}
+		for x := 0; x < width; x++ {
+			if x > 0 {
+				window += colSums[x+2*R] - colSums[x-1]
+			}
+			row[x] = window
+			if !r.Middle {
+				row[x] -= alive(world, boundary, startX+x, y)
+			}
+		}
+		counts[y-startY] = row
+	}
+	return counts
+}
+
+func (r Rule) countVonNeumann(world [][]uint8, boundary Boundary, startY, endY, startX, endX int) [][]int {
+	R := r.Range
+	width := endX - startX
+	// prefix[k][i] is the number of alive cells in row startY-R+k from column startX-R to startX-R+i-1
+	prefix := make([][]int, endY-startY+2*R)
+	for k := range prefix {
+		prefix[k] = make([]int, width+2*R+1)
+		for i := 0; i < width+2*R; i++ {
+			prefix[k][i+1] = prefix[k][i] + alive(world, boundary, startX-R+i, startY-R+k)
+		}
+	}
+
+	counts := make([][]int, endY-startY)
+	for y := 0; y < endY-startY; y++ {
+		row := make([]int, width)
+		for x := 0; x < width; x++ {
+			for dy := -R; dy <= R; dy++ {
+				reach := R - dy
+				if dy < 0 {
+					reach = R + dy
+				}
+				// Columns x-reach to x+reach are at indices x+R-reach to x+R+reach
+				line := prefix[y+R+dy]
+				row[x] += line[x+R+reach+1] - line[x+R-reach]
+			}
+			if !r.Middle {
+				row[x] -= alive(world, boundary, startX+x, startY+y)
+			}
+		}
+		counts[y] = row
+	}
+	return counts
+}
@@ -0,0 +1,229 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// workerReport is what a worker sends back to the distributor after each turn.
+type workerReport struct {
+	flipped CellsFlipped
+	alive   int // alive cells in the worker's strip
+}
+
+// haloSend is a row a worker sends to a halo row of another worker, or of itself, after each turn.
+type haloSend struct {
+	row  int // index of the row in the sender's rows
+	flip bool
+	to   chan []uint8
+}
+
+// haloReceive is a halo row a worker receives before each turn.
+type haloReceive struct {
+	slot int // index of the halo row in the receiver's rows
+	from chan []uint8
+}
+
+// stripWorker owns the rows startY..endY-1 of the world for the whole run.
+// Its rows are stored with radius halo rows above and below, holding the rows beyond
+// the strip as the boundary sees them. Only halo rows are exchanged between workers.
+type stripWorker struct {
+	rule     Rule
+	boundary Boundary
+	tiles    *activeTiles
+	startY   int
+	endY     int
+	radius   int
+	current  [][]uint8
+	next     [][]uint8
+	alive    int
+	sends    []haloSend
+	receives []haloReceive
+	step     chan int
+	report   chan workerReport
+}
+
+// workerPool is the set of long-lived workers of the Bytes engine, one per strip of rows.
+type workerPool struct {
+	workers []*stripWorker
+}
+
+// newWorkerPool splits world into strips and starts a worker for each.
+// tiles is read by the workers during a turn and must only be changed between turns.
+func newWorkerPool(world [][]uint8, rule Rule, boundary Boundary, tiles *activeTiles, threads int) *workerPool {
+	height := len(world)
+	width := len(world[0])
+	if threads > height {
+		threads = height
+	}
+	radius := 1
+	if rule.IsLargerThanLife() {
+		radius = rule.Range
+	}
+
+	unitY := height / threads
+	pool := &workerPool{}
+	for i := 0; i < threads; i++ {
+		w := &stripWorker{
+			rule:     rule,
+			boundary: boundary,
+			tiles:    tiles,
+			startY:   unitY * i,
+			endY:     unitY * (i + 1),
+			radius:   radius,
+			step:     make(chan int),
+			report:   make(chan workerReport),
+		}
+		if i == threads-1 {
+			w.endY = height
+		}
+		rows := w.endY - w.startY + 2*radius
+		w.current = make([][]uint8, rows)
+		w.next = make([][]uint8, rows)
+		for k := range w.current {
+			w.current[k] = make([]uint8, width)
+			w.next[k] = make([]uint8, width)
+		}
+		for y := w.startY; y < w.endY; y++ {
+			copy(w.current[y-w.startY+radius], world[y])
+			copy(w.next[y-w.startY+radius], world[y])
+		}
+		w.alive = currentAliveCells(w.endY-w.startY, width, world[w.startY:w.endY])
+		pool.workers = append(pool.workers, w)
+	}
+
+	// Connect every halo row to the worker owning the row seen there
+	owner := func(y int) *stripWorker {
+		i := y / unitY
+		if i >= threads {
+			i = threads - 1
+		}
+		return pool.workers[i]
+	}
+	for _, w := range pool.workers {
+		for slot := range w.current {
+			y := w.startY - radius + slot
+			if y >= w.startY && y < w.endY {
+				continue
+			}
+			row, flip, dead := boundary.row(y, height)
+			if dead {
+				continue
+			}
+			ch := make(chan []uint8, 1)
+			w.receives = append(w.receives, haloReceive{slot, ch})
+			source := owner(row)
+			source.sends = append(source.sends, haloSend{row - source.startY + radius, flip, ch})
+		}
+	}
+
+	for _, w := range pool.workers {
+		go w.run()
+	}
+	return pool
+}
+
+// step runs one turn on every worker and returns their reports in strip order.
+func (pool *workerPool) step(turn int) []workerReport {
+	for _, w := range pool.workers {
+		w.step <- turn
+	}
+	reports := make([]workerReport, len(pool.workers))
+	for i, w := range pool.workers {
+		reports[i] = <-w.report
+	}
+	return reports
+}
+
+// stop makes every worker return.
+func (pool *workerPool) stop() {
+	for _, w := range pool.workers {
+		close(w.step)
+	}
+}
+
+func (w *stripWorker) run() {
+	w.sendHalo()
+	for turn := range w.step {
+		for _, r := range w.receives {
+			copy(w.current[r.slot], <-r.from)
+		}
+		flipped := w.stepStrip(turn)
+		w.sendHalo()
+		w.report <- workerReport{flipped, w.alive}
+	}
+}
+
+// sendHalo sends copies of the rows other workers see beyond their strips.
+func (w *stripWorker) sendHalo() {
+	for _, s := range w.sends {
+		row := make([]uint8, len(w.current[s.row]))
+		if s.flip {
+			for x := range row {
+				row[x] = w.current[s.row][len(row)-1-x]
+			}
+		} else {
+			copy(row, w.current[s.row])
+		}
+		s.to <- row
+	}
+}
+
+// stepStrip calculates the next state of the strip. Only cells in active tiles are recomputed,
+// the others keep the value they have in both current and next.
+func (w *stripWorker) stepStrip(turn int) CellsFlipped {
+	cellsFlipped := CellsFlipped{
+		CompletedTurns: turn,
+	}
+	height := w.endY - w.startY
+	width := len(w.current[0])
+
+	// Larger than Life neighbourhoods are counted for the whole strip up front
+	var counts [][]int
+	if w.rule.IsLargerThanLife() {
+		counts = w.rule.CountNeighbours(w.current, w.boundary, w.radius, w.radius+height, 0, width)
+	}
+
+	// The halo rows make every row above and below available, only columns need the boundary
+	for i := w.radius; i < w.radius+height; i++ {
+		y := w.startY + i - w.radius
+		above, row, below := w.current[i-1], w.current[i], w.current[i+1]
+		for j := 0; j < width; j++ {
+			if !w.tiles.isActive(j, y) {
+				// Nothing changed around this tile in the last turn, so its cells cannot change
+				j += tileSize - 1 - j%tileSize
+				continue
+			}
+
+			var neighbours int
+			if counts != nil {
+				neighbours = counts[i-w.radius][j]
+			} else if w.boundary != Torus && (j == 0 || j == width-1) {
+				neighbours = w.boundary.Neighbours(w.current, j, i)
+			} else {
+				// Only alive cells count, dying cells of Generations rules hold grey levels below 255
+				left, right := (j-1+width)%width, (j+1)%width
+				neighbours = int(above[left]/255) + int(above[j]/255) + int(above[right]/255) +
+					int(row[left]/255) + int(row[right]/255) +
+					int(below[left]/255) + int(below[j]/255) + int(below[right]/255)
+			}
+
+			w.next[i][j] = w.rule.Step(row[j], neighbours)
+			if w.next[i][j] != row[j] {
+				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{X: j, Y: y})
+				if w.rule.IsGenerations() {
+					cellsFlipped.Deltas = append(cellsFlipped.Deltas, w.next[i][j]^row[j])
+				}
+			}
+		}
+	}
+
+	// Bring current up to date from the flipped cells rather than swapping, so next stays equal to it
+	for _, cell := range cellsFlipped.Cells {
+		i := cell.Y - w.startY + w.radius
+		if w.next[i][cell.X] == 255 {
+			w.alive++
+		} else if w.current[i][cell.X] == 255 {
+			w.alive--
+		}
+		w.current[i][cell.X] = w.next[i][cell.X]
+	}
+	return cellsFlipped
+}
@@ -0,0 +1,191 @@
+package gol
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// recordingDelay is how long each frame of a recording is shown for, in hundredths of a second.
+const recordingDelay = 5
+
+// Recording selects the turns of a run that are recorded as an animated GIF, see Record.
+type Recording struct {
+	Start   bool   // record from the start of the run rather than from when 'r' is pressed
+	Every   int    // record every Every turns from From, 0 records every turn
+	From    int    // first turn that may be recorded
+	To      int    // last turn that may be recorded, 0 for no limit
+	Scale   int    // pixels per side of each cell, 0 for one
+	Palette string // colours of the cells, see ParsePalette, defaults to grey
+}
+
+// Records returns whether the frame of turn is recorded while recording.
+func (r Recording) Records(turn int) bool {
+	if turn < r.From || (r.To > 0 && turn > r.To) {
+		return false
+	}
+	return r.Every <= 1 || (turn-r.From)%r.Every == 0
+}
+
+// palettes are the dead and alive colours of each palette, the dying states of Generations rules are shades between.
+var palettes = map[string][2]color.RGBA{
+	"grey":    {{0, 0, 0, 255}, {255, 255, 255, 255}},
+	"inverse": {{255, 255, 255, 255}, {0, 0, 0, 255}},
+	"green":   {{0, 24, 8, 255}, {64, 255, 96, 255}},
+	"amber":   {{24, 12, 0, 255}, {255, 176, 0, 255}},
+	"blue":    {{0, 16, 32, 255}, {96, 200, 255, 255}},
+}
+
+// ParsePalette returns the palette named grey, inverse, green, amber or blue. Its 256 colours are indexed by the
+// value of a cell, so dead cells have the first colour, alive ones the last and dying ones those between.
+func ParsePalette(name string) (color.Palette, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = "grey"
+	}
+	colours, ok := palettes[name]
+	if !ok {
+		return nil, fmt.Errorf("palette %q: expected one of grey, inverse, green, amber or blue", name)
+	}
+	palette := make(color.Palette, 256)
+	dead, alive := colours[0], colours[1]
+	shade := func(from, to uint8, i int) uint8 {
+		return uint8((int(from)*(255-i) + int(to)*i) / 255)
+	}
+	for i := range palette {
+		palette[i] = color.RGBA{shade(dead.R, alive.R, i), shade(dead.G, alive.G, i), shade(dead.B, alive.B, i), 255}
+	}
+	return palette, nil
+}
+
+// ParseTurnRange parses a range of turns such as "100-200", or "100-" without an end. The end is 0 when there is none.
+func ParseTurnRange(s string) (from, to int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("turns %q: expected from-to", s)
+	}
+	if from, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil || from < 0 {
+		return 0, 0, fmt.Errorf("turns %q: expected from-to", s)
+	}
+	if end := strings.TrimSpace(parts[1]); end != "" {
+		if to, err = strconv.Atoi(end); err != nil || to < from {
+			return 0, 0, fmt.Errorf("turns %q: expected from-to with to no less than from", s)
+		}
+	}
+	return from, to, nil
+}
+
+// recorder follows the world through the events of a run and captures the frames of a recording.
+type recorder struct {
+	p         Params
+	palette   color.Palette
+	world     [][]uint8
+	recording bool
+	first     int // turn of the first frame
+	animation gif.GIF
+}
+
+// Record returns the channel Run should send its events on, which are passed on to events. While recording the
+// frames of the turns p.Recording selects are captured from the CellsFlipped and TurnComplete events, and
+// written to out/ as an animated GIF when recording stops, the selected turns end or the run quits. Recording
+// starts with the run if p.Recording.Start, and RecordingToggled events from the 'r' key start and stop it.
+// p must have the size of the world, as the events do not carry it.
+func Record(p Params, events chan<- Event) chan<- Event {
+	palette, err := ParsePalette(p.Recording.Palette)
+	util.Check(err)
+	r := recorder{p: p, palette: palette, recording: p.Recording.Start}
+	r.world = make([][]uint8, p.ImageHeight)
+	for y := range r.world {
+		r.world[y] = make([]uint8, p.ImageWidth)
+	}
+
+	runEvents := make(chan Event, cap(events))
+	go func() {
+		for event := range runEvents {
+			switch e := event.(type) {
+			case CellFlipped:
+				r.flip(e.Cell, 255)
+			case CellsFlipped:
+				for i, cell := range e.Cells {
+					if e.Deltas != nil {
+						r.flip(cell, e.Deltas[i])
+					} else {
+						r.flip(cell, 255)
+					}
+				}
+			case TurnComplete:
+				if r.recording && p.Recording.Records(e.CompletedTurns) {
+					r.capture(e.CompletedTurns)
+				} else if p.Recording.To > 0 && e.CompletedTurns > p.Recording.To {
+					r.write(events, e.CompletedTurns)
+				}
+			case RecordingToggled:
+				if r.recording && !e.Recording {
+					r.write(events, e.CompletedTurns)
+				}
+				r.recording = e.Recording
+			case StateChange:
+				// The window may close as soon as it sees Quitting, so the recording is written first
+				if e.NewState == Quitting {
+					r.write(events, e.CompletedTurns)
+				}
+			}
+			events <- event
+		}
+		close(events)
+	}()
+	return runEvents
+}
+
+// flip changes cell by delta, leaving out the cells of unbounded worlds outside the image that was loaded.
+func (r *recorder) flip(cell util.Cell, delta uint8) {
+	if cell.X < 0 || cell.Y < 0 || cell.Y >= len(r.world) || cell.X >= len(r.world[cell.Y]) {
+		return
+	}
+	r.world[cell.Y][cell.X] ^= delta
+}
+
+// capture adds the world after turn as the next frame.
+func (r *recorder) capture(turn int) {
+	scale := r.p.Recording.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	frame := image.NewPaletted(image.Rect(0, 0, r.p.ImageWidth*scale, r.p.ImageHeight*scale), r.palette)
+	for y := 0; y < frame.Rect.Dy(); y++ {
+		row := frame.Pix[y*frame.Stride : y*frame.Stride+frame.Rect.Dx()]
+		for x := range row {
+			row[x] = r.world[y/scale][x/scale]
+		}
+	}
+	if len(r.animation.Image) == 0 {
+		r.first = turn
+	}
+	r.animation.Image = append(r.animation.Image, frame)
+	r.animation.Delay = append(r.animation.Delay, recordingDelay)
+}
+
+// write writes the frames captured so far, if any, and reports it with a RecordingComplete event.
+func (r *recorder) write(events chan<- Event, turn int) {
+	frames := len(r.animation.Image)
+	if frames == 0 {
+		return
+	}
+	filename := OutputName(r.p, r.first)
+	file, err := createOutput(filename + ".gif")
+	util.Check(err)
+	defer file.Close()
+	util.Check(gif.EncodeAll(file, &r.animation))
+	util.Check(file.Sync())
+	r.animation = gif.GIF{}
+	events <- RecordingComplete{turn, filename, frames}
+}
@@ -0,0 +1,238 @@
+package gol
+
+import (
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// chunkSize is the side of the square chunks a sparse world is stored in.
+const chunkSize = 64
+
+// chunkKey is the position of a chunk, the chunk holding cell (x, y) is at (floorDiv(x, chunkSize), floorDiv(y, chunkSize)).
+type chunkKey struct {
+	X, Y int
+}
+
+type chunk [chunkSize][chunkSize]uint8
+
+// sparseWorld is the world of the Unbounded boundary. Only chunks holding alive or dying cells are
+// stored, so the world grows in every direction and coordinates may be negative.
+type sparseWorld struct {
+	rule   Rule
+	chunks map[chunkKey]*chunk
+	alive  int
+}
+
+// newSparseWorld places world with its top left cell at origin.
+func newSparseWorld(world [][]uint8, origin util.Cell, rule Rule) *sparseWorld {
+	s := &sparseWorld{
+		rule:   rule,
+		chunks: make(map[chunkKey]*chunk),
+	}
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell == 0 {
+				continue
+			}
+			cellX, cellY := x+origin.X, y+origin.Y
+			key := chunkKey{floorDiv(cellX, chunkSize), floorDiv(cellY, chunkSize)}
+			if s.chunks[key] == nil {
+				s.chunks[key] = new(chunk)
+			}
+			s.chunks[key][wrap(cellY, chunkSize)][wrap(cellX, chunkSize)] = cell
+			if cell == 255 {
+				s.alive++
+			}
+		}
+	}
+	return s
+}
+
+// step computes the next turn with the chunks split between threads workers
+// and returns the cells each of them flipped.
+func (s *sparseWorld) step(turn int, threads int) []CellsFlipped {
+	// Cells can only be born next to a stored chunk
+	candidates := make(map[chunkKey]bool)
+	for key := range s.chunks {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				candidates[chunkKey{key.X + dx, key.Y + dy}] = true
+			}
+		}
+	}
+	keys := make([]chunkKey, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	if threads > len(keys) {
+		threads = len(keys)
+	}
+	next := make([]map[chunkKey]*chunk, threads)
+	alive := make([]int, threads)
+	flipped := make([]CellsFlipped, threads)
+	var wg sync.WaitGroup
+	unit := len(keys) / threads
+	wg.Add(threads)
+	for i := 0; i < threads; i++ {
+		start := unit * i
+		end := unit * (i + 1)
+		if i == threads-1 {
+			end = len(keys)
+		}
+		go func(i int, keys []chunkKey) {
+			defer wg.Done()
+			flipped[i] = CellsFlipped{
+				CompletedTurns: turn,
+			}
+			next[i] = make(map[chunkKey]*chunk)
+			for _, key := range keys {
+				if stepped := s.stepChunk(key, &flipped[i], &alive[i]); stepped != nil {
+					next[i][key] = stepped
+				}
+			}
+		}(i, keys[start:end])
+	}
+	wg.Wait()
+
+	s.chunks = make(map[chunkKey]*chunk, len(s.chunks))
+	s.alive = 0
+	for i := range next {
+		for key, stepped := range next[i] {
+			s.chunks[key] = stepped
+		}
+		s.alive += alive[i]
+	}
+	return flipped
+}
+
+// stepChunk returns the next state of the chunk at key, or nil if all its cells are dead.
+// It appends the cells that changed to cellsFlipped and adds the alive cells to alive.
+func (s *sparseWorld) stepChunk(key chunkKey, cellsFlipped *CellsFlipped, alive *int) *chunk {
+	// Copy the chunk with a border of one cell from its neighbours
+	var padded [chunkSize + 2][chunkSize + 2]uint8
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			neighbour := s.chunks[chunkKey{key.X + dx, key.Y + dy}]
+			if neighbour == nil {
+				continue
+			}
+			startY, endY := border(dy)
+			startX, endX := border(dx)
+			for y := startY; y < endY; y++ {
+				for x := startX; x < endX; x++ {
+					padded[y+1+dy*chunkSize][x+1+dx*chunkSize] = neighbour[y][x]
+				}
+			}
+		}
+	}
+
+	var stepped chunk
+	empty := true
+	for y := 1; y <= chunkSize; y++ {
+		for x := 1; x <= chunkSize; x++ {
+			// Only alive cells count, dying cells of Generations rules hold grey levels below 255
+			neighbours := int(padded[y-1][x-1]/255) + int(padded[y-1][x]/255) + int(padded[y-1][x+1]/255) +
+				int(padded[y][x-1]/255) + int(padded[y][x+1]/255) +
+				int(padded[y+1][x-1]/255) + int(padded[y+1][x]/255) + int(padded[y+1][x+1]/255)
+			cell := s.rule.Step(padded[y][x], neighbours)
+			stepped[y-1][x-1] = cell
+			if cell != 0 {
+				empty = false
+				if cell == 255 {
+					*alive++
+				}
+			}
+			if cell != padded[y][x] {
+				cellsFlipped.Cells = append(cellsFlipped.Cells, util.Cell{X: key.X*chunkSize + x - 1, Y: key.Y*chunkSize + y - 1})
+				if s.rule.IsGenerations() {
+					cellsFlipped.Deltas = append(cellsFlipped.Deltas, cell^padded[y][x])
+				}
+			}
+		}
+	}
+	if empty {
+		return nil
+	}
+	return &stepped
+}
+
+// hash returns the same hash as HashWorld would for a world holding every cell, however far out.
+func (s *sparseWorld) hash() uint64 {
+	var hash uint64
+	for key, ch := range s.chunks {
+		for y := 0; y < chunkSize; y++ {
+			for x := 0; x < chunkSize; x++ {
+				hash ^= CellHash(key.X*chunkSize+x, key.Y*chunkSize+y, ch[y][x])
+			}
+		}
+	}
+	return hash
+}
+
+// border returns the rows or columns of the neighbouring chunk d chunks away that
+// fall within the one cell border around a chunk.
+func border(d int) (start, end int) {
+	switch d {
+	case -1:
+		return chunkSize - 1, chunkSize
+	case 1:
+		return 0, 1
+	default:
+		return 0, chunkSize
+	}
+}
+
+// crop returns the smallest world holding every alive or dying cell and the position of its top left cell.
+func (s *sparseWorld) crop() ([][]uint8, util.Cell) {
+	first := true
+	var min, max util.Cell
+	for key, ch := range s.chunks {
+		for y := 0; y < chunkSize; y++ {
+			for x := 0; x < chunkSize; x++ {
+				if ch[y][x] == 0 {
+					continue
+				}
+				cell := util.Cell{X: key.X*chunkSize + x, Y: key.Y*chunkSize + y}
+				if first {
+					min, max = cell, cell
+					first = false
+				}
+				if cell.X < min.X {
+					min.X = cell.X
+				}
+				if cell.Y < min.Y {
+					min.Y = cell.Y
+				}
+				if cell.X > max.X {
+					max.X = cell.X
+				}
+				if cell.Y > max.Y {
+					max.Y = cell.Y
+				}
+			}
+		}
+	}
+	if first {
+		return [][]uint8{}, util.Cell{}
+	}
+
+	world := make([][]uint8, max.Y-min.Y+1)
+	for y := range world {
+		world[y] = make([]uint8, max.X-min.X+1)
+	}
+	for key, ch := range s.chunks {
+		for y := 0; y < chunkSize; y++ {
+			for x := 0; x < chunkSize; x++ {
+				if ch[y][x] != 0 {
+					world[key.Y*chunkSize+y-min.Y][key.X*chunkSize+x-min.X] = ch[y][x]
+				}
+			}
+		}
+	}
+	return world, min
+}
@@ -0,0 +1,72 @@
+package gol
+
+import "time"
+
+// speeds are the targets in turns per second '+' and '-' step through.
+// Above the fastest comes 0, as fast as possible.
+var speeds = []int{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// FasterSpeed returns the next target above turnsPerSecond, 0 being as fast as possible.
+func FasterSpeed(turnsPerSecond int) int {
+	if turnsPerSecond <= 0 {
+		return 0
+	}
+	for _, speed := range speeds {
+		if speed > turnsPerSecond {
+			return speed
+		}
+	}
+	return 0
+}
+
+// SlowerSpeed returns the next target below turnsPerSecond, 0 being as fast as possible.
+func SlowerSpeed(turnsPerSecond int) int {
+	if turnsPerSecond <= 0 {
+		return speeds[len(speeds)-1]
+	}
+	for i := len(speeds) - 1; i >= 0; i-- {
+		if speeds[i] < turnsPerSecond {
+			return speeds[i]
+		}
+	}
+	return speeds[0]
+}
+
+// Throttle paces turns to at most TurnsPerSecond, 0 means as fast as possible.
+type Throttle struct {
+	TurnsPerSecond int
+	next           time.Time // when the next turn is due
+}
+
+// Due returns whether the next turn may start now. Once it has returned true the turn
+// after is due a 1/TurnsPerSecond later. A throttle that fell behind, for example while
+// paused, does not try to catch up.
+func (t *Throttle) Due() bool {
+	if t.TurnsPerSecond <= 0 {
+		return true
+	}
+	now := time.Now()
+	if now.Before(t.next) {
+		return false
+	}
+	interval := time.Second / time.Duration(t.TurnsPerSecond)
+	if now.Sub(t.next) > interval {
+		t.next = now
+	}
+	t.next = t.next.Add(interval)
+	return true
+}
+
+// Wait sleeps until the next turn is due, but no longer than max.
+func (t *Throttle) Wait(max time.Duration) {
+	if t.TurnsPerSecond <= 0 {
+		return
+	}
+	wait := time.Until(t.next)
+	if wait > max {
+		wait = max
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
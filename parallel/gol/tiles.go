@@ -0,0 +1,97 @@
+package gol
+
+// tileSize is the side of the square tiles the world is split into for tracking changes.
+const tileSize = 16
+
+// activeTiles tracks which tiles of the world can change in the next turn. A cell can
+// only change if a cell in its neighbourhood changed in the previous turn, so only the
+// tiles around those that changed need to be recomputed. Still-life ash is skipped.
+type activeTiles struct {
+	columns, rows int
+	reach         int // how many tiles away a change can have an effect
+	boundary      Boundary
+	active        []bool
+	changed       []bool
+}
+
+func newActiveTiles(width, height int, rule Rule, boundary Boundary) *activeTiles {
+	columns := (width + tileSize - 1) / tileSize
+	rows := (height + tileSize - 1) / tileSize
+	radius := 1
+	if rule.IsLargerThanLife() {
+		radius = rule.Range
+	}
+	reach := (radius + tileSize - 1) / tileSize
+	if width%tileSize != 0 || height%tileSize != 0 {
+		// A partial tile on the far edge may be narrower than the neighbourhood
+		reach++
+	}
+	a := &activeTiles{
+		columns:  columns,
+		rows:     rows,
+		reach:    reach,
+		boundary: boundary,
+		active:   make([]bool, columns*rows),
+		changed:  make([]bool, columns*rows),
+	}
+	// Everything has to be computed in the first turn
+	for i := range a.active {
+		a.active[i] = true
+	}
+	return a
+}
+
+// isActive returns whether the cell at (x, y) has to be recomputed this turn.
+func (a *activeTiles) isActive(x, y int) bool {
+	return a.active[(y/tileSize)*a.columns+x/tileSize]
+}
+
+// markChanged records that the cell at (x, y) changed this turn.
+func (a *activeTiles) markChanged(x, y int) {
+	a.changed[(y/tileSize)*a.columns+x/tileSize] = true
+}
+
+// advance makes the tiles around those that changed active for the next turn.
+func (a *activeTiles) advance() {
+	for i := range a.active {
+		a.active[i] = false
+	}
+	nearEdge := false
+	for ty := 0; ty < a.rows; ty++ {
+		for tx := 0; tx < a.columns; tx++ {
+			if !a.changed[ty*a.columns+tx] {
+				continue
+			}
+			a.changed[ty*a.columns+tx] = false
+			if a.isNearEdge(tx, ty) {
+				nearEdge = true
+			}
+			for dy := -a.reach; dy <= a.reach; dy++ {
+				for dx := -a.reach; dx <= a.reach; dx++ {
+					x, y := tx+dx, ty+dy
+					if a.boundary == Torus {
+						x, y = wrap(x, a.columns), wrap(y, a.rows)
+					} else if x < 0 || x >= a.columns || y < 0 || y >= a.rows {
+						continue
+					}
+					a.active[y*a.columns+x] = true
+				}
+			}
+		}
+	}
+	if nearEdge && a.boundary != Torus && a.boundary != Dead {
+		// Changes near an edge are seen on the other side of the boundary, which may
+		// be the opposite or mirrored edge, so every tile near an edge is recomputed
+		for ty := 0; ty < a.rows; ty++ {
+			for tx := 0; tx < a.columns; tx++ {
+				if a.isNearEdge(tx, ty) {
+					a.active[ty*a.columns+tx] = true
+				}
+			}
+		}
+	}
+}
+
+func (a *activeTiles) isNearEdge(tx, ty int) bool {
+	return tx < a.reach || ty < a.reach || tx >= a.columns-a.reach || ty >= a.rows-a.reach
+}
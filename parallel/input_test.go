@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestInputSized tests that a world is sized from the header of any input file, with padding around it.
+func TestInputSized(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{Turns: 100, Threads: 4, Input: "images/16x16.pgm"}
+	_, final := runAlive(p)
+	assertEqualBoard(t, final.Alive, readAliveCells("check/images/16x16x100.pgm", 16, 16), gol.Params{ImageWidth: 16, ImageHeight: 16})
+
+	_ = os.WriteFile("out/glider.rle", []byte(gliderRLE), 0644)
+	pattern, err := gol.ReadPatternFile("out/glider.rle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = pattern.Apply(gol.Params{Input: "out/glider.rle", Padding: 2})
+	if p.ImageWidth != 7 || p.ImageHeight != 7 {
+		t.Errorf("padded world is %vx%v, expected 7x7", p.ImageWidth, p.ImageHeight)
+	}
+}
+
+// TestInputOffset tests that an input is placed at the offset in a larger world, and centred without one.
+func TestInputOffset(t *testing.T) {
+	emptyOutFolder()
+	image := readAliveCells("check/images/16x16x0.pgm", 16, 16)
+	tests := []struct {
+		offset   *util.Cell
+		expected util.Cell
+	}{
+		{&util.Cell{X: 3, Y: 10}, util.Cell{X: 3, Y: 10}},
+		{nil, util.Cell{X: 8, Y: 8}},
+	}
+	for _, test := range tests {
+		p := gol.Params{ImageWidth: 32, ImageHeight: 32, Turns: 0, Threads: 4, Input: "images/16x16.pgm", Offset: test.offset}
+		_, final := runAlive(p)
+		expected := make([]util.Cell, len(image))
+		for i, cell := range image {
+			expected[i] = util.Cell{X: cell.X + test.expected.X, Y: cell.Y + test.expected.Y}
+		}
+		assertEqualBoard(t, final.Alive, expected, p)
+	}
+
+	pattern, err := gol.ReadPatternFile("images/16x16.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []util.Cell{{X: 17, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 20}} {
+		if _, err := pattern.Place(32, 32, &offset, gol.Conway); err == nil {
+			t.Errorf("16x16 image placed at %v in a world of 32x32", offset)
+		}
+	}
+}
+
+// TestOutputName tests that output images are named from the template, also in directories of it.
+func TestOutputName(t *testing.T) {
+	emptyOutFolder()
+	_ = os.WriteFile("out/glider.rle", []byte(gliderRLE), 0644)
+	p := gol.Params{ImageWidth: 8, ImageHeight: 8, Turns: 4, Threads: 1, Input: "out/glider.rle", OutputName: "{input}/{w}-{h}-t{turn}"}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var filename string
+	for event := range events {
+		if e, ok := event.(gol.ImageOutputComplete); ok {
+			filename = e.Filename
+		}
+	}
+	if filename != "glider/8-8-t4" {
+		t.Errorf("image output as %q, expected glider/8-8-t4", filename)
+	}
+	if _, err := os.Stat("out/glider/8-8-t4.pgm"); err != nil {
+		t.Error(err)
+	}
+
+	if name := gol.OutputName(gol.Params{ImageWidth: 16, ImageHeight: 32}, 7); name != "16x32x7" {
+		t.Errorf("default output name %q, expected 16x32x7", name)
+	}
+	for _, broken := range []string{"{turns}", "out{w", "{w}}"} {
+		if err := gol.CheckOutputName(broken); err == nil {
+			t.Errorf("output name %q accepted", broken)
+		}
+	}
+}
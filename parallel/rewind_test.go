@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRewind pauses a run, steps back and forward with 'b' and 'n', saves the world shown and quits.
+// The saved image must be the world at the turn stepped back to, and both the final board and the board
+// rebuilt from CellsFlipped events must be the latest world again.
+func TestRewind(t *testing.T) {
+	for _, engine := range []gol.Engine{gol.Bytes, gol.BitPacked} {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 1000000000, Threads: 4, Engine: engine, History: 10}
+		t.Run(engine.String(), func(t *testing.T) {
+			emptyOutFolder()
+			keyPresses := make(chan rune, 10)
+			events := make(chan gol.Event, 1000)
+			go gol.Run(p, events, keyPresses)
+
+			flipped := make(map[util.Cell]bool)
+			var states []gol.StateChange
+			var final gol.FinalTurnComplete
+			for quitting := false; !quitting; {
+				switch e := (<-events).(type) {
+				case gol.CellsFlipped:
+					for _, cell := range e.Cells {
+						flipped[cell] = !flipped[cell]
+					}
+				case gol.TurnComplete:
+					if e.CompletedTurns == 20 {
+						keyPresses <- 'p'
+					}
+				case gol.StateChange:
+					states = append(states, e)
+					quitting = e.NewState == gol.Quitting
+					if e.NewState == gol.Paused && len(states) == 2 {
+						// Step back further than the history goes, then forward again
+						for i := 0; i < 12; i++ {
+							keyPresses <- 'b'
+						}
+						for i := 0; i < 3; i++ {
+							keyPresses <- 'n'
+						}
+					}
+					if len(states) == 15 {
+						keyPresses <- 's'
+					}
+				case gol.ImageOutputComplete:
+					if len(states) == 15 {
+						keyPresses <- 'q'
+					}
+				case gol.FinalTurnComplete:
+					final = e
+				}
+			}
+
+			paused := states[1].CompletedTurns
+			var turns []int
+			for _, state := range states[2:15] {
+				turns = append(turns, state.CompletedTurns)
+			}
+			var expectedTurns []int
+			for i := 1; i <= 10; i++ {
+				expectedTurns = append(expectedTurns, paused-i)
+			}
+			expectedTurns = append(expectedTurns, paused-9, paused-8, paused-7)
+			if fmt.Sprint(turns) != fmt.Sprint(expectedTurns) {
+				t.Fatalf("stepping back and forward from turn %v reported turns %v, expected %v", paused, turns, expectedTurns)
+			}
+
+			initial := readAliveCells("check/images/64x64x0.pgm", p.ImageWidth, p.ImageHeight)
+			saved := readAliveCells(fmt.Sprintf("out/64x64x%v.pgm", paused-7), p.ImageWidth, p.ImageHeight)
+			assertEqualBoard(t, saved, simulate(initial, gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: paused - 7}), p)
+
+			expectedAlive := simulate(initial, gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: final.CompletedTurns})
+			assertEqualBoard(t, final.Alive, expectedAlive, p)
+			var replayed []util.Cell
+			for cell, alive := range flipped {
+				if alive {
+					replayed = append(replayed, cell)
+				}
+			}
+			assertEqualBoard(t, replayed, expectedAlive, p)
+		})
+	}
+}
@@ -34,10 +34,20 @@ sdl:
 						keyPresses <- 'p'
 					case sdl.K_s:
 						keyPresses <- 's'
+					case sdl.K_r:
+						keyPresses <- 'r'
 					case sdl.K_q:
 						keyPresses <- 'q'
 					case sdl.K_k:
 						keyPresses <- 'k'
+					case sdl.K_PLUS, sdl.K_EQUALS, sdl.K_KP_PLUS:
+						keyPresses <- '+'
+					case sdl.K_MINUS, sdl.K_KP_MINUS:
+						keyPresses <- '-'
+					case sdl.K_b:
+						keyPresses <- 'b'
+					case sdl.K_n:
+						keyPresses <- 'n'
 					}
 				}
 			}
@@ -54,8 +64,16 @@ sdl:
 			case gol.CellFlipped:
 				w.FlipPixel(e.Cell.X, e.Cell.Y)
 			case gol.CellsFlipped:
-				for _, cell := range e.Cells {
-					w.FlipPixel(cell.X, cell.Y) 
+				for i, cell := range e.Cells {
+					if p.Boundary == gol.Unbounded && (cell.X < 0 || cell.Y < 0 || cell.X >= p.ImageWidth || cell.Y >= p.ImageHeight) {
+						// The window only shows the part of an unbounded world where the image was loaded
+						continue
+					}
+					if e.Deltas != nil {
+						w.XorPixel(cell.X, cell.Y, e.Deltas[i])
+					} else {
+						w.FlipPixel(cell.X, cell.Y)
+					}
 				}
 			case gol.TurnComplete:
 				dirty = true
@@ -65,7 +83,19 @@ sdl:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.ImageOutputComplete:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.ImageInputFailed:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.CycleDetected:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.SpeedChanged:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.RecordingToggled:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.RecordingComplete:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.StateChange:
+				// Stepping back and forward while paused changes cells without completing a turn
+				dirty = true
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 				if e.NewState == gol.Quitting {
 					break sdl
@@ -85,6 +115,16 @@ func RunHeadless(events <-chan gol.Event) {
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), "Final Turn Complete")
 		case gol.ImageOutputComplete:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.ImageInputFailed:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.CycleDetected:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.SpeedChanged:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.RecordingToggled:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.RecordingComplete:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 		case gol.StateChange:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			if e.NewState == gol.Quitting {
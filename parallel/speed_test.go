@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSingleStep pauses a run, advances it three turns with 'n' and quits.
+func TestSingleStep(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1000000000, Threads: 4}
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	keyPresses <- 'p'
+	go gol.Run(p, events, keyPresses)
+
+	var states []gol.StateChange
+	var final gol.FinalTurnComplete
+	for quitting := false; !quitting; {
+		switch e := (<-events).(type) {
+		case gol.StateChange:
+			states = append(states, e)
+			quitting = e.NewState == gol.Quitting
+			if e.NewState == gol.Paused && len(states) == 2 {
+				keyPresses <- 'n'
+				keyPresses <- 'n'
+				keyPresses <- 'n'
+			}
+			if len(states) == 5 {
+				keyPresses <- 'q'
+			}
+		case gol.FinalTurnComplete:
+			final = e
+		}
+	}
+
+	paused := states[1].CompletedTurns
+	expected := []gol.StateChange{{paused + 1, gol.Paused}, {paused + 2, gol.Paused}, {paused + 3, gol.Paused}}
+	if fmt.Sprint(states[2:5]) != fmt.Sprint(expected) {
+		t.Errorf("single steps from turn %v reported %v, expected %v", paused, states[2:5], expected)
+	}
+	if final.CompletedTurns != paused+3 {
+		t.Errorf("final turn %v, expected %v", final.CompletedTurns, paused+3)
+	}
+	initial := readAliveCells("check/images/16x16x0.pgm", p.ImageWidth, p.ImageHeight)
+	assertEqualBoard(t, final.Alive, simulate(initial, gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: paused + 3}), p)
+}
+
+// TestSpeed slows a run down with '-' and checks that its turns are paced to the new target.
+func TestSpeed(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 4, TurnsPerSecond: 20}
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	keyPresses <- '-'
+	start := time.Now()
+	go gol.Run(p, events, keyPresses)
+
+	var speeds []int
+	for event := range events {
+		if e, ok := event.(gol.SpeedChanged); ok {
+			speeds = append(speeds, e.TurnsPerSecond)
+		}
+	}
+	if fmt.Sprint(speeds) != "[10]" {
+		t.Errorf("SpeedChanged events reported %v turns/sec, expected [10]", speeds)
+	}
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+		t.Errorf("10 turns at 10 turns/sec took %v", elapsed)
+	}
+}